@@ -0,0 +1,177 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// collectCgroup collects the metrics of the cgroup v2 of the monitored
+// process.
+// Metrics of controllers that are not enabled for the cgroup are left to
+// their zero value.
+func (c *collector) collectCgroup() *CgroupInfos {
+	path, err := cgroupPath(c.pid)
+	if err != nil {
+		c.msg.Printf("could not find cgroup of pid=%d: %+v", c.pid, err)
+		return nil
+	}
+	var (
+		dir = filepath.Join(c.cgroup.root, path)
+		cg  = CgroupInfos{Path: path}
+	)
+
+	if v, err := readCgroupValue(dir, "memory.current"); err == nil {
+		cg.Memory = v / 1024 // in kB
+	}
+
+	var anon, file, kernel, sock int64
+	_ = readCgroupKV(dir, "memory.stat", map[string]*int64{
+		"anon":   &anon,
+		"file":   &file,
+		"kernel": &kernel,
+		"sock":   &sock,
+	})
+	cg.Anon = anon / 1024     // in kB
+	cg.File = file / 1024     // in kB
+	cg.Kernel = kernel / 1024 // in kB
+	cg.Sock = sock / 1024     // in kB
+
+	_ = readCgroupKV(dir, "memory.events", map[string]*int64{
+		"high":     &cg.High,
+		"oom":      &cg.OOM,
+		"oom_kill": &cg.OOMKill,
+	})
+
+	var usage, throttled int64
+	_ = readCgroupKV(dir, "cpu.stat", map[string]*int64{
+		"usage_usec":     &usage,
+		"nr_throttled":   &cg.NrThrottled,
+		"throttled_usec": &throttled,
+	})
+	cg.CPU = time.Duration(usage) * time.Microsecond
+	cg.Throttled = time.Duration(throttled) * time.Microsecond
+
+	var rbytes, wbytes int64
+	_ = readCgroupIO(dir, map[string]*int64{
+		"rbytes": &rbytes,
+		"wbytes": &wbytes,
+		"rios":   &cg.Rios,
+		"wios":   &cg.Wios,
+	})
+	cg.Rdisk = rbytes / 1024 // in kB
+	cg.Wdisk = wbytes / 1024 // in kB
+
+	return &cg
+}
+
+// cgroupRoot returns the mount point of the cgroup v2 hierarchy.
+func cgroupRoot() (string, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// device mount-point fs-type options dump pass
+		fields := strings.Fields(sc.Text())
+		if len(fields) > 2 && fields[2] == "cgroup2" {
+			return fields[1], nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("could not find cgroup2 mount point")
+}
+
+// cgroupPath returns the path of the cgroup v2 of the provided process,
+// relative to the cgroup v2 mount point.
+func cgroupPath(pid int) (string, error) {
+	raw, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 for pid=%d", pid)
+}
+
+// readCgroupValue reads a single-value cgroup file.
+func readCgroupValue(dir, name string) (int64, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+}
+
+// readCgroupKV reads the requested keys of a flat-keyed "key value" cgroup
+// file (memory.stat, memory.events, cpu.stat, ...).
+func readCgroupKV(dir, name string, keys map[string]*int64) error {
+	raw, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		k, v, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		ptr, ok := keys[k]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse %s value of %q: %w", name, k, err)
+		}
+		*ptr = n
+	}
+	return nil
+}
+
+// readCgroupIO reads the requested keys of the io.stat cgroup file, summed
+// over all devices.
+func readCgroupIO(dir string, keys map[string]*int64) error {
+	raw, err := os.ReadFile(filepath.Join(dir, "io.stat"))
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		// major:minor rbytes=N wbytes=N rios=N wios=N dbytes=N dios=N
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			k, v, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			ptr, ok := keys[k]
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("could not parse io.stat value of %q: %w", k, err)
+			}
+			*ptr += n
+		}
+	}
+	return nil
+}
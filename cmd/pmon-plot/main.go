@@ -5,16 +5,21 @@
 package main
 
 import (
+	"cmp"
 	"flag"
 	"fmt"
 	"image/color"
 	"log"
+	"math"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/sbinet/pmon"
 	"go-hep.org/x/hep/hbook"
 	"go-hep.org/x/hep/hplot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
 	"gonum.org/v1/plot/vg"
 	"gonum.org/v1/plot/vg/draw"
 )
@@ -84,12 +89,40 @@ func run(oname, fname string) {
 	log.Printf("delta: %v", meta.Elapsed)
 	log.Printf("start: %v", meta.Stop.Format(layout))
 
-	tp := hplot.NewTiledPlot(draw.Tiles{Cols: 1, Rows: 2})
+	panels := []func(p *hplot.Plot, meta pmon.Meta){
+		doVMem,
+		doRSS,
+	}
+	if hasMemory(meta) {
+		panels = append(panels, doMemory)
+	}
+	if hasSched(meta) {
+		panels = append(panels, doCPU)
+	}
+	if hasSyscalls(meta) {
+		panels = append(panels, doIOOps, doIOSize)
+	}
+	if len(meta.Smaps) > 0 {
+		panels = append(panels, doSmaps)
+	}
+	if len(meta.Threads) > 0 {
+		panels = append(panels, doThreads)
+	}
+	if len(meta.States) > 0 {
+		panels = append(panels, doStates)
+	}
+
+	tp := hplot.NewTiledPlot(draw.Tiles{Cols: 1, Rows: len(panels)})
 	tp.Align = true
-	doVMem(tp.Plots[0], meta)
-	doRSS(tp.Plots[1], meta)
+	for i, panel := range panels {
+		panel(tp.Plots[i], meta)
+	}
 
-	err = tp.Save(20*vg.Centimeter, -1, oname)
+	const (
+		width = 20 * vg.Centimeter
+		row   = width / math.Phi / 2 // height of a panel
+	)
+	err = tp.Save(width, vg.Length(len(panels))*row, oname)
 	if err != nil {
 		log.Fatalf("could not save output plot: %+v", err)
 	}
@@ -109,6 +142,14 @@ func doVMem(p *hplot.Plot, meta pmon.Meta) {
 	s2.LineStyle.Color = color.RGBA{R: 255, A: 255}
 	s2.LineStyle.Width = vg.Points(2)
 
+	if hasMemory(meta) {
+		peak := make([]float64, len(meta.Infos))
+		for i, v := range meta.Infos {
+			peak[i] = float64(v.VMemPeak) * MB
+		}
+		p.Add(newPeak(xs, peak))
+	}
+
 	p.Title.Text = "VMem [MB]"
 	p.X.Label.Text = "Time [s]"
 	p.Y.Label.Text = "VMem [MB]"
@@ -130,9 +171,381 @@ func doRSS(p *hplot.Plot, meta pmon.Meta) {
 	s2.LineStyle.Color = color.RGBA{R: 255, A: 255}
 	s2.LineStyle.Width = vg.Points(2)
 
+	if hasMemory(meta) {
+		peak := make([]float64, len(meta.Infos))
+		for i, v := range meta.Infos {
+			peak[i] = float64(v.RSSPeak) * MB
+		}
+		p.Add(newPeak(xs, peak))
+	}
+
 	p.Title.Text = "RSS [MB]"
 	p.X.Label.Text = "Time [s]"
 	p.Y.Label.Text = "RSS [MB]"
+	p.Legend.Top = true
+	p.Legend.Left = true
 
 	p.Add(s2, hplot.NewGrid())
+	addEvents(p, meta, xs, ys)
+}
+
+// hasMemory returns whether the pmon run holds the breakdown of the memory
+// usage from /proc/<pid>/status.
+func hasMemory(meta pmon.Meta) bool {
+	for _, v := range meta.Infos {
+		if v.RSSPeak > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// newPeak returns a dashed line displaying the peak values of a series.
+func newPeak(xs, ys []float64) *hplot.S2D {
+	s2 := hplot.NewS2D(hbook.NewS2DFrom(xs, ys))
+	s2.LineStyle.Color = color.RGBA{R: 255, A: 255}
+	s2.LineStyle.Width = vg.Points(1)
+	s2.LineStyle.Dashes = []vg.Length{vg.Points(4), vg.Points(2)}
+	return s2
+}
+
+func doMemory(p *hplot.Plot, meta pmon.Meta) {
+	const MB = 1 / 1024.0
+
+	for i, field := range []struct {
+		name string
+		get  func(v pmon.Infos) int64
+	}{
+		{"RssAnon", func(v pmon.Infos) int64 { return v.RSSAnon }},
+		{"RssFile", func(v pmon.Infos) int64 { return v.RSSFile }},
+		{"RssShmem", func(v pmon.Infos) int64 { return v.RSSShmem }},
+		{"Swap", func(v pmon.Infos) int64 { return v.Swap }},
+	} {
+		xs := make([]float64, len(meta.Infos))
+		ys := make([]float64, len(meta.Infos))
+		for j, v := range meta.Infos {
+			xs[j] = float64(j) * meta.Freq.Seconds()
+			ys[j] = float64(field.get(v)) * MB
+		}
+
+		s2 := hplot.NewS2D(hbook.NewS2DFrom(xs, ys))
+		s2.LineStyle.Color = plotutil.Color(i)
+		s2.LineStyle.Width = vg.Points(2)
+		p.Add(s2)
+		p.Legend.Add(field.name, s2)
+	}
+
+	p.Title.Text = "Memory breakdown [MB]"
+	p.X.Label.Text = "Time [s]"
+	p.Y.Label.Text = "Memory [MB]"
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	p.Add(hplot.NewGrid())
+}
+
+// hasSched returns whether the pmon run holds the scheduler statistics
+// from /proc/<pid>/schedstat.
+func hasSched(meta pmon.Meta) bool {
+	for _, v := range meta.Infos {
+		if v.RunTime > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func doCPU(p *hplot.Plot, meta pmon.Meta) {
+	var ts, usr []float64 // samples of the user time, to overlay the events
+	for i, field := range []struct {
+		name string
+		get  func(v pmon.Infos) time.Duration
+	}{
+		{"User", func(v pmon.Infos) time.Duration { return v.UTime }},
+		{"System", func(v pmon.Infos) time.Duration { return v.STime }},
+		{"Run-queue wait", func(v pmon.Infos) time.Duration { return v.WaitTime }},
+	} {
+		xs := make([]float64, len(meta.Infos))
+		ys := make([]float64, len(meta.Infos))
+		for j, v := range meta.Infos {
+			xs[j] = float64(j) * meta.Freq.Seconds()
+			ys[j] = field.get(v).Seconds()
+		}
+
+		if i == 0 {
+			ts, usr = xs, ys
+		}
+
+		s2 := hplot.NewS2D(hbook.NewS2DFrom(xs, ys))
+		s2.LineStyle.Color = plotutil.Color(i)
+		s2.LineStyle.Width = vg.Points(2)
+		p.Add(s2)
+		p.Legend.Add(field.name, s2)
+	}
+
+	p.Title.Text = "CPU [s]"
+	p.X.Label.Text = "Time [s]"
+	p.Y.Label.Text = "CPU [s]"
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	p.Add(hplot.NewGrid())
+	addEvents(p, meta, ts, usr)
+}
+
+// hasSyscalls returns whether the pmon run holds the number of I/O
+// syscalls from /proc/<pid>/io.
+func hasSyscalls(meta pmon.Meta) bool {
+	for _, v := range meta.Infos {
+		if v.Syscr > 0 || v.Syscw > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func doIOOps(p *hplot.Plot, meta pmon.Meta) {
+	for i, field := range []struct {
+		name string
+		get  func(v pmon.Infos) int64
+	}{
+		{"read", func(v pmon.Infos) int64 { return v.Syscr }},
+		{"write", func(v pmon.Infos) int64 { return v.Syscw }},
+	} {
+		xs := make([]float64, len(meta.Infos))
+		ys := make([]float64, len(meta.Infos))
+		for j, v := range meta.Infos {
+			xs[j] = float64(j) * meta.Freq.Seconds()
+			ys[j] = float64(field.get(v))
+		}
+
+		s2 := hplot.NewS2D(hbook.NewS2DFrom(xs, ys))
+		s2.LineStyle.Color = plotutil.Color(i)
+		s2.LineStyle.Width = vg.Points(2)
+		p.Add(s2)
+		p.Legend.Add(field.name, s2)
+	}
+
+	p.Title.Text = "I/O syscalls"
+	p.X.Label.Text = "Time [s]"
+	p.Y.Label.Text = "Syscalls"
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	p.Add(hplot.NewGrid())
+}
+
+// doIOSize displays the average number of kilobytes transferred per I/O
+// syscall, during each sampling interval.
+// The sizes are approximate: the transferred bytes are logged in kB,
+// truncated, so small transfers are under-estimated.
+func doIOSize(p *hplot.Plot, meta pmon.Meta) {
+	for i, field := range []struct {
+		name  string
+		bytes func(v pmon.Infos) int64
+		calls func(v pmon.Infos) int64
+	}{
+		{"read", func(v pmon.Infos) int64 { return v.Rchar }, func(v pmon.Infos) int64 { return v.Syscr }},
+		{"write", func(v pmon.Infos) int64 { return v.Wchar }, func(v pmon.Infos) int64 { return v.Syscw }},
+	} {
+		var (
+			xs   = make([]float64, 0, len(meta.Infos))
+			ys   = make([]float64, 0, len(meta.Infos))
+			prev pmon.Infos
+		)
+		for j, v := range meta.Infos {
+			calls := field.calls(v) - field.calls(prev)
+			bytes := field.bytes(v) - field.bytes(prev)
+			prev = v
+			if calls <= 0 {
+				continue
+			}
+			xs = append(xs, float64(j)*meta.Freq.Seconds())
+			ys = append(ys, float64(bytes)/float64(calls))
+		}
+		if len(xs) == 0 {
+			continue
+		}
+
+		s2 := hplot.NewS2D(hbook.NewS2DFrom(xs, ys))
+		s2.LineStyle.Color = plotutil.Color(i)
+		s2.LineStyle.Width = vg.Points(2)
+		p.Add(s2)
+		p.Legend.Add(field.name, s2)
+	}
+
+	p.Title.Text = "Average I/O size, approximate [kB/syscall]"
+	p.X.Label.Text = "Time [s]"
+	p.Y.Label.Text = "I/O size [~kB]"
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	p.Add(hplot.NewGrid())
+}
+
+func doSmaps(p *hplot.Plot, meta pmon.Meta) {
+	const MB = 1 / 1024.0
+
+	for i, field := range []struct {
+		name string
+		get  func(v pmon.SmapsInfos) int64
+	}{
+		{"PSS", func(v pmon.SmapsInfos) int64 { return v.PSS }},
+		{"USS", func(v pmon.SmapsInfos) int64 { return v.USS }},
+		{"Swap", func(v pmon.SmapsInfos) int64 { return v.Swap }},
+		{"SwapPSS", func(v pmon.SmapsInfos) int64 { return v.SwapPSS }},
+	} {
+		xs := make([]float64, len(meta.Smaps))
+		ys := make([]float64, len(meta.Smaps))
+		for j, v := range meta.Smaps {
+			xs[j] = float64(v.Tick) * meta.Freq.Seconds()
+			ys[j] = float64(field.get(v)) * MB
+		}
+
+		s2 := hplot.NewS2D(hbook.NewS2DFrom(xs, ys))
+		s2.LineStyle.Color = plotutil.Color(i)
+		s2.LineStyle.Width = vg.Points(2)
+		p.Add(s2)
+		p.Legend.Add(field.name, s2)
+	}
+
+	p.Title.Text = "PSS/USS [MB]"
+	p.X.Label.Text = "Time [s]"
+	p.Y.Label.Text = "Memory [MB]"
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	p.Add(hplot.NewGrid())
+}
+
+func doThreads(p *hplot.Plot, meta pmon.Meta) {
+	const top = 5 // number of hottest thread names to display
+
+	names := make([]string, 0, len(meta.Threads))
+	cpus := make(map[string][]time.Duration, len(meta.Threads))
+	for name := range meta.Threads {
+		names = append(names, name)
+		cpus[name] = meta.ThreadCPU(name)
+	}
+	total := func(name string) time.Duration {
+		cpu := cpus[name]
+		if len(cpu) == 0 {
+			return 0
+		}
+		return cpu[len(cpu)-1]
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Compare(total(b), total(a))
+	})
+	if len(names) > top {
+		names = names[:top]
+	}
+
+	for i, name := range names {
+		cpu := cpus[name]
+		xs := make([]float64, len(cpu))
+		ys := make([]float64, len(cpu))
+		for j, v := range cpu {
+			xs[j] = float64(j) * meta.Freq.Seconds()
+			ys[j] = v.Seconds()
+		}
+
+		s2 := hplot.NewS2D(hbook.NewS2DFrom(xs, ys))
+		s2.LineStyle.Color = plotutil.Color(i)
+		s2.LineStyle.Width = vg.Points(2)
+		p.Add(s2)
+		p.Legend.Add(name, s2)
+	}
+
+	p.Title.Text = "Threads CPU [s]"
+	p.X.Label.Text = "Time [s]"
+	p.Y.Label.Text = "CPU [s]"
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	p.Add(hplot.NewGrid())
+}
+
+// doStates displays a stacked chart of the number of threads in each
+// scheduler state.
+func doStates(p *hplot.Plot, meta pmon.Meta) {
+	fields := []struct {
+		name string
+		get  func(v pmon.StateInfos) int64
+	}{
+		{"running", func(v pmon.StateInfos) int64 { return v.Running }},
+		{"sleeping", func(v pmon.StateInfos) int64 { return v.Sleeping }},
+		{"uninterruptible", func(v pmon.StateInfos) int64 { return v.Disk }},
+		{"other", func(v pmon.StateInfos) int64 { return v.Other }},
+	}
+
+	// stack the states from the bottom up, and draw the largest areas first.
+	stacks := make([]plotter.XYs, len(fields))
+	for i := range fields {
+		stacks[i] = make(plotter.XYs, len(meta.States))
+		for j, v := range meta.States {
+			y := float64(fields[i].get(v))
+			if i > 0 {
+				y += stacks[i-1][j].Y
+			}
+			stacks[i][j].X = float64(v.Tick) * meta.Freq.Seconds()
+			stacks[i][j].Y = y
+		}
+	}
+	for i := len(fields) - 1; i >= 0; i-- {
+		line, err := plotter.NewLine(stacks[i])
+		if err != nil {
+			log.Fatalf("could not create %s threads line: %+v", fields[i].name, err)
+		}
+		line.FillColor = plotutil.Color(i)
+		line.LineStyle.Width = 0
+		p.Add(line)
+		p.Legend.Add(fields[i].name, line)
+	}
+
+	p.Title.Text = "Threads states"
+	p.X.Label.Text = "Time [s]"
+	p.Y.Label.Text = "Threads"
+	p.Legend.Top = true
+	p.Legend.Left = true
+
+	p.Add(hplot.NewGrid())
+}
+
+// addEvents overlays the lifecycle events as markers on the (xs,ys) series,
+// at the value of the series when each event occurred.
+func addEvents(p *hplot.Plot, meta pmon.Meta, xs, ys []float64) {
+	if len(xs) == 0 {
+		return
+	}
+
+	kinds := []string{"fork", "vfork", "clone", "exec", "signal", "exit", "killed", "oom-killed"}
+	for i, kind := range kinds {
+		var xys plotter.XYs
+		for _, v := range meta.Events {
+			if v.Kind != kind {
+				continue
+			}
+			x := v.Time.Seconds()
+			j, ok := slices.BinarySearch(xs, x)
+			if !ok {
+				j-- // last sample before the event
+			}
+			j = min(max(j, 0), len(ys)-1)
+			xys = append(xys, plotter.XY{X: x, Y: ys[j]})
+		}
+		if len(xys) == 0 {
+			continue
+		}
+
+		sca, err := plotter.NewScatter(xys)
+		if err != nil {
+			log.Fatalf("could not create %s events scatter: %+v", kind, err)
+		}
+		sca.GlyphStyle.Color = plotutil.Color(i)
+		sca.GlyphStyle.Shape = plotutil.Shape(i)
+		sca.GlyphStyle.Radius = vg.Points(3)
+		p.Add(sca)
+		p.Legend.Add(kind, sca)
+	}
 }
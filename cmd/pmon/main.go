@@ -6,12 +6,15 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/sbinet/pmon"
@@ -21,6 +24,32 @@ var (
 	freq = flag.Duration("freq", 1*time.Second, "frequence to capture resource usage")
 	out  = flag.String("o", "pmon.data", "path to file to store resources usage log")
 	pid  = flag.Int("p", 0, "PID of an already running process to monitor")
+	tree = flag.Bool("tree", false, "monitor the whole process tree")
+	brkd = flag.Bool("breakdown", false, "record per-process resources usage of the whole process tree")
+	thrd = flag.Bool("threads", false, "record per-thread CPU usage and state")
+	smap = flag.Duration("smaps", 0, "frequence to capture PSS/USS memory usage (0 to disable)")
+	maps = flag.Duration("maps", 0, "frequence to capture memory usage per mapping (0 to disable)")
+	mtop = flag.Int("maps-top", 10, "number of largest mappings to record")
+	fds  = flag.Bool("fds", false, "record the inventory of file descriptors and sockets")
+	prog = flag.Duration("progress", 0, "frequence to capture and report the progress in opened files (0 to disable)")
+	cgrp = flag.Bool("cgroup", false, "record the metrics of the cgroup v2 of the monitored process")
+	psi  = flag.Bool("psi", false, "record the pressure stall information of the system and of the cgroup v2 of the monitored process")
+	host = flag.Bool("host", false, "record system-wide resources usage")
+	offc = flag.Bool("offcpu", false, "record threads states, kernel wait channels and blocking syscalls")
+	strc = flag.Bool("strace", false, "trace syscalls of the command and its descendants, and report a summary at exit")
+	strl = flag.Bool("strace-log", false, "record per-interval syscalls statistics (implies -strace)")
+	evts = flag.Bool("events", false, "record fork, exec, exit and signal events of the command and its descendants")
+	reap = flag.Bool("subreaper", false, "adopt and monitor the orphaned descendants of the command (implies -tree)")
+	grce = flag.Duration("grace", 0, "maximum time to wait for the descendants of the command after it exited (0 to wait for all)")
+	kill = flag.Bool("kill-orphans", false, "kill the descendants still running after the grace period")
+	lbls = make(map[string]string)
+
+	scom = flag.String("comm", "", "monitor the running processes with this exact command name")
+	scmd = flag.String("cmdline", "", "monitor the running processes whose command line matches this regular expression")
+	suid = flag.Int("uid", -1, "monitor the running processes of this real user id")
+	spgr = flag.Int("pgid", 0, "monitor the running processes of this process group")
+	ssid = flag.Int("sid", 0, "monitor the running processes of this session")
+	rscn = flag.Bool("rescan", false, "keep selecting the processes matching -comm, -cmdline, -uid, -pgid and -sid during the run")
 
 	usage = `pmon monitors process resources usage.
 
@@ -33,6 +62,8 @@ Example:
  $ pmon my-command arg0 arg1
  $ pmon -- my-command arg0 arg1
  $ pmon -p 1234
+ $ pmon -comm nginx -rescan
+ $ pmon -uid 1000 -cmdline 'python .*train\.py'
 
 Options:
 `
@@ -48,9 +79,32 @@ func main() {
 		flag.PrintDefaults()
 	}
 
+	flag.Func("label", "key=value label recorded in the log header (may be repeated)", func(s string) error {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid label %q (want key=value)", s)
+		}
+		lbls[k] = v
+		return nil
+	})
+
 	flag.Parse()
 
-	if *pid <= 0 && flag.NArg() <= 0 {
+	sel := pmon.NewSelector()
+	sel.Comm = *scom
+	sel.UID = *suid
+	sel.PGID = *spgr
+	sel.SID = *ssid
+	if *scmd != "" {
+		re, err := regexp.Compile(*scmd)
+		if err != nil {
+			log.Fatalf("invalid -cmdline pattern: %+v", err)
+		}
+		sel.Cmdline = re
+	}
+	selecting := sel.String() != ""
+
+	if *pid <= 0 && !selecting && flag.NArg() <= 0 {
 		log.Printf("expect a command (and its arguments) as argument")
 		flag.Usage()
 		os.Exit(1)
@@ -63,7 +117,18 @@ func main() {
 
 	switch {
 	case *pid > 0:
-		runPID(*out, *pid)
+		proc, err := pmon.Monitor(*pid)
+		if err != nil {
+			log.Fatalf("could not create monitor for process PID=%d: %+v", *pid, err)
+		}
+		runAttach(*out, proc)
+	case selecting:
+		proc, err := pmon.Select(sel)
+		if err != nil {
+			log.Fatalf("could not create monitor for processes %v: %+v", sel, err)
+		}
+		proc.Rescan = *rscn
+		runAttach(*out, proc)
 	default:
 		cmd := flag.Arg(0)
 		args := flag.Args()[1:]
@@ -83,6 +148,25 @@ func runCmd(out, cmd string, args []string) {
 	proc := pmon.New(cmd, args...)
 	proc.W = w
 	proc.Freq = *freq
+	proc.Tree = *tree
+	proc.Breakdown = *brkd
+	proc.Threads = *thrd
+	proc.SmapsFreq = *smap
+	proc.MapsFreq = *maps
+	proc.MapsTop = *mtop
+	proc.FDs = *fds
+	proc.ProgressFreq = *prog
+	proc.Cgroup = *cgrp
+	proc.PSI = *psi
+	proc.Host = *host
+	proc.OffCPU = *offc
+	proc.Strace = *strc || *strl
+	proc.StraceLog = *strl
+	proc.Events = *evts
+	proc.Subreaper = *reap
+	proc.Grace = *grce
+	proc.KillOrphans = *kill
+	proc.Labels = lbls
 
 	go func() {
 		sigch := make(chan os.Signal, 1)
@@ -98,9 +182,21 @@ func runCmd(out, cmd string, args []string) {
 		}
 	}()
 
+	// monitoring errors are always reported, but the log file is completed
+	// before pmon exits with the status of the command, or 1 if the
+	// monitoring of a successful command failed.
+	status := 0
 	err = proc.Run()
-	if err != nil {
-		log.Fatalf("error monitoring process: %+v", err)
+	var exit *pmon.ExitError
+	switch {
+	case errors.As(err, &exit):
+		// the command failed: not a monitoring error.
+	case err != nil:
+		log.Printf("error monitoring process: %+v", err)
+		status = 1
+	}
+	if exit := proc.Exit(); exit != nil && exit.Status() != 0 {
+		status = exit.Status()
 	}
 
 	err = w.Flush()
@@ -112,9 +208,12 @@ func runCmd(out, cmd string, args []string) {
 	if err != nil {
 		log.Fatalf("error closing log file: %+v", err)
 	}
+
+	os.Exit(status)
 }
 
-func runPID(out string, pid int) {
+// runAttach monitors already running process(es).
+func runAttach(out string, proc *pmon.Process) {
 	f, err := os.Create(out)
 	if err != nil {
 		log.Fatalf("could not create output log file: %+v", err)
@@ -123,12 +222,24 @@ func runPID(out string, pid int) {
 
 	w := bufio.NewWriter(f)
 
-	proc, err := pmon.Monitor(pid)
-	if err != nil {
-		log.Fatalf("could not create monitor for process PID=%d: %+v", pid, err)
-	}
 	proc.W = w
 	proc.Freq = *freq
+	proc.Tree = *tree
+	proc.Breakdown = *brkd
+	proc.Threads = *thrd
+	proc.SmapsFreq = *smap
+	proc.MapsFreq = *maps
+	proc.MapsTop = *mtop
+	proc.FDs = *fds
+	proc.ProgressFreq = *prog
+	proc.Cgroup = *cgrp
+	proc.PSI = *psi
+	proc.Host = *host
+	proc.OffCPU = *offc
+	proc.Labels = lbls
+	if *strc || *strl || *evts {
+		log.Printf("syscall and events tracing are not supported when monitoring a running process")
+	}
 
 	go func() {
 		sigch := make(chan os.Signal, 1)
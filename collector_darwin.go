@@ -18,6 +18,7 @@ package pmon
 import "C"
 
 import (
+	"log"
 	"syscall"
 	"time"
 	"unsafe"
@@ -27,7 +28,7 @@ type collector struct {
 	pid int
 }
 
-func newCollector(pid int) (*collector, error) {
+func newCollector(msg *log.Logger, pid int, cfg config) (*collector, error) {
 	return &collector{pid: pid}, nil
 }
 
@@ -35,13 +36,13 @@ func (c *collector) Close() error {
 	return nil
 }
 
-func (c *collector) collect() (Infos, error) {
+func (c *collector) collect() (sample, error) {
 
 	info := C.struct_proc_taskallinfo{}
 
 	err := task_info(c.pid, &info)
 	if err != nil {
-		return Infos{}, err
+		return sample{}, err
 	}
 
 	var (
@@ -59,9 +60,29 @@ func (c *collector) collect() (Infos, error) {
 		Wchar:   -1,
 		Rdisk:   -1,
 		Wdisk:   -1,
+
+		RSSAnon:  -1,
+		RSSFile:  -1,
+		RSSShmem: -1,
+		Swap:     -1,
+		RSSPeak:  -1,
+		VMemPeak: -1,
+
+		MinFlt:  -1,
+		MajFlt:  -1,
+		CMinFlt: -1,
+		CMajFlt: -1,
+
+		VolCtxSw:   -1,
+		InvolCtxSw: -1,
+		Timeslices: -1,
+
+		Syscr:  -1,
+		Syscw:  -1,
+		Cwdisk: -1,
 	}
 
-	return infos, err
+	return sample{Infos: infos}, err
 }
 
 func task_info(pid int, info *C.struct_proc_taskallinfo) error {
@@ -5,90 +5,251 @@
 package pmon
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type collector struct {
-	msg  *log.Logger
-	stat *os.File
-	io   *os.File
+	msg    *log.Logger
+	pid    int
+	stat   *os.File
+	io     *os.File
+	status *os.File
+	sched  *os.File
+
+	tree      bool            // whether to monitor the whole process tree
+	breakdown bool            // whether to record per-process infos
+	threads   bool            // whether to record per-thread infos
+	psi       bool            // whether to record pressure stall information
+	host      bool            // whether to record system-wide resources usage
+	offcpu    bool            // whether to record threads states, wait channels and syscalls
+	fds       bool            // whether to record the file descriptors inventory
+	subreaper bool            // whether processes adopted by the current process belong to the tree
+	orphans   *orphans        // descendants of the command, in subreaper mode
+	descend   bool            // whether descendants of the monitored processes belong to the tree
+	sel       *Selector       // selector of the monitored processes, if any
+	rescan    bool            // whether to select new matching processes at each sample
+	procs     map[int]*member // live processes of the monitored tree
+	schedstat bool            // whether scheduler statistics are available
+	table     procTable       // snapshot of the running processes shared by a session, if any
+
+	peak struct {
+		rss  int64 // largest sampled resident set size of the tree (kB)
+		vmem int64 // largest sampled virtual memory size of the tree (kB)
+	}
+
+	gone struct {
+		usr uint64 // user time of the exited members not reaped by the tree
+		sys uint64 // system time of the exited members not reaped by the tree
+		io  ioStat // I/O of the exited members not reaped by the tree
+	}
+
+	smaps struct {
+		freq time.Duration // sampling period of smaps_rollup
+		last time.Time     // time of the last smaps_rollup sampling
+	}
+
+	maps struct {
+		freq time.Duration // sampling period of smaps
+		last time.Time     // time of the last smaps sampling
+		top  int           // number of mappings to record
+	}
+
+	cgroup struct {
+		enabled bool   // whether to record cgroup v2 metrics
+		root    string // mount point of the cgroup v2 hierarchy
+	}
+
+	files struct {
+		freq  time.Duration            // sampling period of files positions
+		last  time.Time                // time of the last files positions sampling
+		progs map[fileKey]fileProgress // last recorded positions
+	}
 }
 
-func newCollector(msg *log.Logger, pid int) (*collector, error) {
-	dir := "/proc/" + strconv.Itoa(pid)
-	stat, err := os.Open(dir + "/stat")
+// member describes a monitored process.
+type member struct {
+	stat   procStat
+	io     ioStat
+	status procStatus
+	sched  schedStat
+}
+
+func newCollector(msg *log.Logger, pid int, cfg config) (*collector, error) {
+	c := &collector{
+		msg:       msg,
+		pid:       pid,
+		tree:      cfg.tree || cfg.breakdown || cfg.subreaper || cfg.sel != nil,
+		descend:   cfg.sel == nil || cfg.tree || cfg.breakdown,
+		breakdown: cfg.breakdown,
+		threads:   cfg.threads,
+		fds:       cfg.fds,
+		psi:       cfg.psi,
+		host:      cfg.host,
+		offcpu:    cfg.offcpu,
+		subreaper: cfg.subreaper,
+		orphans:   cfg.orphans,
+		sel:       cfg.sel,
+		rescan:    cfg.rescan,
+	}
+	c.smaps.freq = cfg.smaps
+	c.maps.freq = cfg.maps
+	c.files.freq = cfg.progress
+	if cfg.cgroup || cfg.psi {
+		root, err := cgroupRoot()
+		if err != nil {
+			msg.Printf("could not find cgroup v2 hierarchy: %+v", err)
+		}
+		c.cgroup.enabled = err == nil && cfg.cgroup
+		c.cgroup.root = root
+	}
+	c.maps.top = cfg.mapsTop
+	if c.maps.top <= 0 {
+		c.maps.top = 10
+	}
+	if c.tree {
+		c.procs = make(map[int]*member)
+	}
+	if _, err := os.Stat("/proc/self/schedstat"); err == nil {
+		c.schedstat = true
+	} else {
+		// kernel built without CONFIG_SCHED_INFO.
+		msg.Printf("no scheduler statistics: %+v", err)
+	}
+
+	if c.sel != nil {
+		// the selected processes are read from /proc/<pid> at each sample.
+		for _, pid := range cfg.pids {
+			if stat, err := readStat(pid); err == nil {
+				c.procs[pid] = &member{stat: stat}
+			}
+		}
+		return c, nil
+	}
+
+	var (
+		dir = "/proc/" + strconv.Itoa(pid)
+		err error
+	)
+	c.stat, err = os.Open(dir + "/stat")
 	if err != nil {
 		msg.Printf("could not open /proc/%d/stat: %+v", pid, err)
 		return nil, err
 	}
 
-	io, err := os.Open(dir + "/io")
+	c.io, err = os.Open(dir + "/io")
 	if err != nil {
 		msg.Printf("could not open /proc/%d/io: %+v", pid, err)
 		return nil, err
 	}
 
-	return &collector{msg: msg, stat: stat, io: io}, nil
+	c.status, err = os.Open(dir + "/status")
+	if err != nil {
+		msg.Printf("could not open /proc/%d/status: %+v", pid, err)
+		return nil, err
+	}
+
+	if c.schedstat {
+		c.sched, err = os.Open(dir + "/schedstat")
+		if err != nil {
+			msg.Printf("could not open /proc/%d/schedstat: %+v", pid, err)
+			c.schedstat = false
+		}
+	}
+
+	return c, nil
 }
 
 func (c *collector) Close() error {
+	if c.sel != nil {
+		return nil
+	}
 	err1 := c.stat.Close()
 	err2 := c.io.Close()
+	err3 := c.status.Close()
+	var err4 error
+	if c.sched != nil {
+		err4 = c.sched.Close()
+	}
 	if err1 != nil {
 		return err1
 	}
 	if err2 != nil {
 		return err2
 	}
+	if err3 != nil {
+		return err3
+	}
+	if err4 != nil {
+		return err4
+	}
 	return nil
 }
 
 const (
-	statfmt = "%d %s %c %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d"
+	statfmt = "%c %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d"
 )
 
-func (c *collector) collect() (Infos, error) {
-	// see: http://man7.org/linux/man-pages/man5/proc.5.html
-	stat := struct {
-		pid       int    // process ID
-		comm      string // filename of the executable in parentheses
-		state     byte   // process state
-		ppid      int    // pid of the parent process
-		pgrp      int    // process group ID of the process
-		session   int    // session ID of the process
-		tty       int    // controlling terminal of the process
-		tpgid     int    // ID of foreground process group
-		flags     uint32 // kernel flags word of the process
-		minflt    uint64 // number of minor faults the process has made which have not required loading a memory page from disk
-		cminflt   uint64 // number of minor faults the process's waited-for children have made
-		majflt    uint64 // number of major faults the process has made which have required loading a memory page from disk
-		cmajflt   uint64 // number of major faults the process's waited-for children have made
-		utime     uint64 // user time in clock ticks
-		stime     uint64 // system time in clock ticks
-		cutime    int64  // children user time in clock ticks
-		cstime    int64  // children system time in clock ticks
-		priority  int64  // priority
-		nice      int64  // the nice value
-		nthreads  int64  // number of threads in this process
-		itrealval int64  // time in jiffies before next SIGALRM is sent to the process dure to an interval timer
-		starttime int64  // time the process started after system boot in clock ticks
-		vsize     uint64 // virtual memory size in bytes
-		rss       int64  // resident set size: number of pages the process has in real memory
-	}{}
-
-	_, err := c.stat.Seek(0, 0)
-	if err != nil {
-		c.msg.Printf("could not rewind %s: %+v", c.stat.Name(), err)
-		return Infos{}, err
-	}
-
-	_, err = fmt.Fscanf(
-		c.stat, statfmt,
-		&stat.pid, &stat.comm, &stat.state,
+// procStat holds the content of /proc/<pid>/stat.
+//
+// see: http://man7.org/linux/man-pages/man5/proc.5.html
+type procStat struct {
+	pid       int    // process ID
+	comm      string // filename of the executable
+	state     byte   // process state
+	ppid      int    // pid of the parent process
+	pgrp      int    // process group ID of the process
+	session   int    // session ID of the process
+	tty       int    // controlling terminal of the process
+	tpgid     int    // ID of foreground process group
+	flags     uint32 // kernel flags word of the process
+	minflt    uint64 // number of minor faults the process has made which have not required loading a memory page from disk
+	cminflt   uint64 // number of minor faults the process's waited-for children have made
+	majflt    uint64 // number of major faults the process has made which have required loading a memory page from disk
+	cmajflt   uint64 // number of major faults the process's waited-for children have made
+	utime     uint64 // user time in clock ticks
+	stime     uint64 // system time in clock ticks
+	cutime    int64  // children user time in clock ticks
+	cstime    int64  // children system time in clock ticks
+	priority  int64  // priority
+	nice      int64  // the nice value
+	nthreads  int64  // number of threads in this process
+	itrealval int64  // time in jiffies before next SIGALRM is sent to the process dure to an interval timer
+	starttime int64  // time the process started after system boot in clock ticks
+	vsize     uint64 // virtual memory size in bytes
+	rss       int64  // resident set size: number of pages the process has in real memory
+}
+
+// parseStat parses the content of a /proc/<pid>/stat file.
+// The executable name may contain spaces and parentheses, so it is
+// extracted from the first '(' up to the last ')'.
+func parseStat(data []byte) (procStat, error) {
+	var stat procStat
+
+	beg := bytes.IndexByte(data, '(')
+	end := bytes.LastIndexByte(data, ')')
+	if beg < 0 || end < beg || end+2 > len(data) {
+		return stat, fmt.Errorf("invalid stat content %q", data)
+	}
+
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(data[:beg])))
+	if err != nil {
+		return stat, fmt.Errorf("invalid stat pid: %w", err)
+	}
+	stat.pid = pid
+	stat.comm = string(data[beg+1 : end])
+
+	_, err = fmt.Sscanf(
+		string(data[end+2:]), statfmt,
+		&stat.state,
 		&stat.ppid, &stat.pgrp, &stat.session,
 		&stat.tty, &stat.tpgid, &stat.flags,
 		&stat.minflt, &stat.cminflt, &stat.majflt, &stat.cmajflt,
@@ -100,56 +261,715 @@ func (c *collector) collect() (Infos, error) {
 		&stat.itrealval, &stat.starttime,
 		&stat.vsize, &stat.rss,
 	)
+	if err != nil {
+		return stat, err
+	}
+
+	return stat, nil
+}
+
+// ioStat holds the content of /proc/<pid>/io.
+type ioStat struct {
+	rchar int64 // number of bytes read
+	wchar int64 // number of bytes written
+	syscr int64 // number of read syscalls
+	syscw int64 // number of write syscalls
+	rdisk int64 // number of bytes read from storage
+	wdisk int64 // number of bytes written to storage
+	cdisk int64 // number of bytes whose writing to storage was cancelled
+}
+
+// parseIO parses the content of a /proc/<pid>/io file.
+func parseIO(r io.Reader) (ioStat, error) {
+	var io ioStat
+	_, err := fmt.Fscanf(
+		r,
+		"rchar: %d\nwchar: %d\nsyscr: %d\nsyscw: %d\nread_bytes: %d\nwrite_bytes: %d\ncancelled_write_bytes: %d\n",
+		&io.rchar, &io.wchar,
+		&io.syscr, &io.syscw,
+		&io.rdisk, &io.wdisk,
+		&io.cdisk,
+	)
+	return io, err
+}
+
+func (io *ioStat) add(o ioStat) {
+	io.rchar += o.rchar
+	io.wchar += o.wchar
+	io.syscr += o.syscr
+	io.syscw += o.syscw
+	io.rdisk += o.rdisk
+	io.wdisk += o.wdisk
+	io.cdisk += o.cdisk
+}
+
+// procStatus holds the memory (in kB) and context switches fields of
+// /proc/<pid>/status.
+type procStatus struct {
+	rssAnon  int64 // size of resident anonymous memory
+	rssFile  int64 // size of resident file mappings
+	rssShmem int64 // size of resident shared memory
+	vmSwap   int64 // swapped-out virtual memory size
+	vmHWM    int64 // peak resident set size ("high water mark")
+	vmPeak   int64 // peak virtual memory size
+	volCtx   int64 // number of voluntary context switches
+	nvolCtx  int64 // number of involuntary context switches
+}
+
+// parseStatus parses the content of a /proc/<pid>/status file.
+func parseStatus(data []byte) (procStatus, error) {
+	var status procStatus
+	err := scanKV(data, map[string]*int64{
+		"RssAnon":  &status.rssAnon,
+		"RssFile":  &status.rssFile,
+		"RssShmem": &status.rssShmem,
+		"VmSwap":   &status.vmSwap,
+		"VmHWM":    &status.vmHWM,
+		"VmPeak":   &status.vmPeak,
+
+		"voluntary_ctxt_switches":    &status.volCtx,
+		"nonvoluntary_ctxt_switches": &status.nvolCtx,
+	})
+	return status, err
+}
+
+func (status *procStatus) add(o procStatus) {
+	status.rssAnon += o.rssAnon
+	status.rssFile += o.rssFile
+	status.rssShmem += o.rssShmem
+	status.vmSwap += o.vmSwap
+	// peaks are not additive: the peaks of processes that were not at
+	// their peak at the same time do not sum up.
+	status.vmHWM = max(status.vmHWM, o.vmHWM)
+	status.vmPeak = max(status.vmPeak, o.vmPeak)
+	status.volCtx += o.volCtx
+	status.nvolCtx += o.nvolCtx
+}
+
+// schedStat holds the content of /proc/<pid>/schedstat.
+type schedStat struct {
+	run    int64 // time spent on the CPU, in nanoseconds
+	wait   int64 // time spent waiting on a run-queue, in nanoseconds
+	slices int64 // number of timeslices run on this CPU
+}
+
+// parseSchedStat parses the content of a /proc/<pid>/schedstat file.
+func parseSchedStat(data []byte) (schedStat, error) {
+	var sched schedStat
+	_, err := fmt.Sscanf(
+		string(data), "%d %d %d",
+		&sched.run, &sched.wait, &sched.slices,
+	)
+	return sched, err
+}
+
+func (sched *schedStat) add(o schedStat) {
+	sched.run += o.run
+	sched.wait += o.wait
+	sched.slices += o.slices
+}
+
+func (c *collector) collect() (sample, error) {
+	s, err := c.collectProcs()
+	if err != nil {
+		return s, err
+	}
+
+	if c.threads {
+		s.threads = c.collectThreads()
+	}
+
+	if c.offcpu {
+		s.states, s.wchans, s.syscalls = c.collectOffCPU()
+	}
+
+	if c.fds {
+		s.fds, s.socks = c.collectFDs()
+	}
+
+	if c.cgroup.enabled {
+		s.cgroup = c.collectCgroup()
+	}
+
+	if c.psi {
+		s.psi = c.collectPSI()
+	}
+
+	if c.host {
+		s.host = c.collectHost()
+	}
+
+	if now := time.Now(); c.smaps.freq > 0 && now.Sub(c.smaps.last) >= c.smaps.freq {
+		c.smaps.last = now
+		s.smaps = c.collectSmaps()
+	}
+
+	if now := time.Now(); c.maps.freq > 0 && now.Sub(c.maps.last) >= c.maps.freq {
+		c.maps.last = now
+		s.maps = c.collectMaps()
+	}
+
+	if now := time.Now(); c.files.freq > 0 && now.Sub(c.files.last) >= c.files.freq {
+		c.files.last = now
+		s.files = c.collectFiles(now)
+	}
+
+	return s, nil
+}
+
+// pids returns the list of currently monitored processes.
+func (c *collector) pids() []int {
+	if !c.tree {
+		return []int{c.pid}
+	}
+	pids := make([]int, 0, len(c.procs))
+	for pid := range c.procs {
+		pids = append(pids, pid)
+	}
+	slices.Sort(pids)
+	return pids
+}
+
+func (c *collector) collectProcs() (sample, error) {
+	if c.tree {
+		return c.collectTree()
+	}
+
+	var m member
+
+	raw, err := reread(c.stat)
+	if err != nil {
+		c.msg.Printf("could not read %s: %+v", c.stat.Name(), err)
+		return sample{}, err
+	}
+
+	m.stat, err = parseStat(raw)
 	if err != nil {
 		c.msg.Printf("error collecting CPU/Mem data: %+v", err)
-		return Infos{}, err
+		return sample{}, err
 	}
 
 	_, err = c.io.Seek(0, 0)
 	if err != nil {
 		c.msg.Printf("could not rewind %s: %+v", c.io.Name(), err)
-		return Infos{}, err
+		return sample{}, err
 	}
 
-	var (
-		rchar int64
-		wchar int64
-		syscr int64
-		syscw int64
-		rdisk int64
-		wdisk int64
-	)
-	_, err = fmt.Fscanf(
-		c.io,
-		"rchar: %d\nwchar: %d\nsyscr: %d\nsyscw: %d\nread_bytes: %d\nwrite_bytes: %d\n",
-		&rchar, &wchar,
-		&syscr, &syscw,
-		&rdisk, &wdisk,
-	)
+	m.io, err = parseIO(c.io)
 	if err != nil {
 		c.msg.Printf("error collecting I/O data: %+v", err)
-		return Infos{}, err
+		return sample{}, err
+	}
+
+	raw, err = reread(c.status)
+	if err != nil {
+		c.msg.Printf("could not read %s: %+v", c.status.Name(), err)
+		return sample{}, err
+	}
+
+	m.status, err = parseStatus(raw)
+	if err != nil {
+		c.msg.Printf("error collecting memory data: %+v", err)
+		return sample{}, err
+	}
+
+	if c.sched != nil {
+		raw, err = reread(c.sched)
+		if err != nil {
+			c.msg.Printf("could not read %s: %+v", c.sched.Name(), err)
+			return sample{}, err
+		}
+
+		m.sched, err = parseSchedStat(raw)
+		if err != nil {
+			c.msg.Printf("error collecting scheduler data: %+v", err)
+			return sample{}, err
+		}
 	}
 
-	infos := Infos{
-		CPU:     time.Duration((stat.utime + stat.stime) * clockTicksToNanosecond),
-		UTime:   time.Duration(stat.utime * clockTicksToNanosecond),
-		STime:   time.Duration(stat.stime * clockTicksToNanosecond),
-		VMem:    int64(stat.vsize) / 1024,   // in kB
-		RSS:     stat.rss * PageSize / 1024, // in kB
-		Threads: stat.nthreads,
-		Rchar:   rchar / 1024, // in kB
-		Wchar:   wchar / 1024, // in kB
-		Rdisk:   rdisk / 1024, // in kB
-		Wdisk:   wdisk / 1024, // in kB
+	var u usage
+	u.add(&m)
+	u.lead = m.stat
+
+	return sample{Infos: c.infos(u)}, nil
+}
+
+// infos returns the monitoring informations of the provided usage, marking
+// the scheduler statistics as unavailable on kernels without them.
+func (c *collector) infos(u usage) Infos {
+	infos := u.infos()
+	if !c.schedstat {
+		infos.Timeslices = -1
 	}
-	return infos, nil
+	return infos
 }
 
-/*
-syscr: 6
-syscw: 0
-read_bytes: 0
-write_bytes: 0
+// collectTree collects resources usage summed over the monitored process
+// and all its live descendants.
+//
+// Descendants are discovered by walking the ppid links of /proc/*/stat.
+// A process that has been seen in the tree stays in the tree even if it is
+// re-parented (e.g. to init) after its parent exited.
+// Processes of the process group of the monitored process are also part of
+// the tree, to catch the descendants orphaned before they could be seen.
+//
+// The CPU time and I/O of exited descendants are accounted for by the kernel
+// in the cutime/cstime and /proc/<pid>/io of the processes that reaped them.
+// The ones of the members that exited without being reaped by another member
+// (e.g. after they were re-parented to init) are carried forward from their
+// last sample.
+func (c *collector) collectTree() (sample, error) {
+	stats := map[int]procStat(c.table)
+	c.table = nil
+	if stats == nil {
+		var err error
+		stats, err = scanProcs()
+		if err != nil {
+			c.msg.Printf("could not scan processes: %+v", err)
+			return sample{}, err
+		}
+	}
 
-*/
+	pids := c.members(stats)
+	switch {
+	case len(pids) == 0 && c.sel != nil:
+		return sample{}, errNoMatch
+	case len(pids) == 0 && c.zombie(c.pid, stats):
+		return sample{}, errExited
+	case len(pids) == 0:
+		return sample{}, fmt.Errorf("no live process in tree of pid=%d", c.pid)
+	}
+
+	if c.sel != nil {
+		// the lead is the oldest root of the selected trees.
+		c.pid = 0
+		for pid := range pids {
+			if _, ok := pids[stats[pid].ppid]; ok {
+				continue
+			}
+			if c.pid == 0 || stats[pid].starttime < stats[c.pid].starttime {
+				c.pid = pid
+			}
+		}
+	}
+
+	// the time of the exited members is carried forward, but for the part
+	// of it that was reaped by a live member, and is now in its cutime/cstime.
+	reaped := make(map[int]procStat) // time reaped by live members, indexed by pid
+	for pid, m := range c.procs {
+		if _, ok := pids[pid]; ok || c.zombie(pid, stats) {
+			continue
+		}
+		usr := m.stat.utime + uint64(max(m.stat.cutime, 0))
+		sys := m.stat.stime + uint64(max(m.stat.cstime, 0))
+		c.gone.usr += usr
+		c.gone.sys += sys
+		reaper, ok := c.reaper(m, pids, stats)
+		if !ok {
+			c.gone.io.add(m.io)
+			continue
+		}
+		v := reaped[reaper]
+		v.utime += usr
+		v.stime += sys
+		reaped[reaper] = v
+	}
+	for pid, v := range reaped {
+		// the reaper may also have lost its parent before reaping the
+		// exited members: only the growth of its cutime/cstime is theirs.
+		var last procStat
+		if m, ok := c.procs[pid]; ok {
+			last = m.stat
+		}
+		c.gone.usr -= min(v.utime, uint64(max(stats[pid].cutime-last.cutime, 0)))
+		c.gone.sys -= min(v.stime, uint64(max(stats[pid].cstime-last.cstime, 0)))
+	}
+	for pid := range c.procs {
+		if _, ok := pids[pid]; !ok && !c.zombie(pid, stats) {
+			delete(c.procs, pid)
+		}
+	}
+
+	u := usage{
+		usr: c.gone.usr,
+		sys: c.gone.sys,
+		io:  c.gone.io,
+	}
+	for pid := range pids {
+		m, ok := c.procs[pid]
+		if !ok {
+			m = &member{}
+			c.procs[pid] = m
+		}
+		m.stat = stats[pid]
+		if io, err := readIO(pid); err == nil {
+			m.io = io
+		}
+		if status, err := readStatus(pid); err == nil {
+			m.status = status
+		}
+		if c.schedstat {
+			if sched, err := readSchedStat(pid); err == nil {
+				m.sched = sched
+			}
+		}
+
+		u.add(m)
+		u.usr += uint64(max(m.stat.cutime, 0))
+		u.sys += uint64(max(m.stat.cstime, 0))
+		if pid == c.pid {
+			u.lead = m.stat
+		}
+	}
+	for pid, m := range c.procs {
+		if _, ok := pids[pid]; ok {
+			continue
+		}
+		// zombies are not reaped yet: their time is still their own.
+		m.stat = stats[pid]
+		u.usr += m.stat.utime + uint64(max(m.stat.cutime, 0))
+		u.sys += m.stat.stime + uint64(max(m.stat.cstime, 0))
+	}
+
+	var procs []ProcInfos
+	if c.breakdown {
+		procs = make([]ProcInfos, 0, len(pids))
+		for pid := range pids {
+			m := c.procs[pid]
+			procs = append(procs, ProcInfos{
+				PID:   pid,
+				PPID:  m.stat.ppid,
+				Comm:  m.stat.comm,
+				CPU:   time.Duration((m.stat.utime + m.stat.stime) * clockTicksToNanosecond),
+				RSS:   m.stat.rss * PageSize / 1024, // in kB
+				Rchar: m.io.rchar / 1024,            // in kB
+				Wchar: m.io.wchar / 1024,            // in kB
+				Rdisk: m.io.rdisk / 1024,            // in kB
+				Wdisk: m.io.wdisk / 1024,            // in kB
+			})
+		}
+		slices.SortFunc(procs, func(a, b ProcInfos) int { return a.PID - b.PID })
+	}
+
+	// the peaks of the tree are the largest sampled sizes of the tree, or
+	// the largest peak of one of its members.
+	infos := c.infos(u)
+	c.peak.rss = max(c.peak.rss, infos.RSS, infos.RSSPeak)
+	c.peak.vmem = max(c.peak.vmem, infos.VMem, infos.VMemPeak)
+	infos.RSSPeak = c.peak.rss
+	infos.VMemPeak = c.peak.vmem
+
+	return sample{Infos: infos, procs: procs}, nil
+}
+
+// usage holds resources usage summed over a set of processes.
+type usage struct {
+	usr      uint64 // user time in clock ticks
+	sys      uint64 // system time in clock ticks
+	cusr     int64  // children user time in clock ticks
+	csys     int64  // children system time in clock ticks
+	vmem     uint64 // virtual memory size in bytes
+	rss      int64  // resident set size in pages
+	nthreads int64  // number of threads
+	minflt   uint64 // number of minor faults
+	majflt   uint64 // number of major faults
+	cminflt  uint64 // number of minor faults of the waited-for children
+	cmajflt  uint64 // number of major faults of the waited-for children
+	io       ioStat
+	status   procStatus
+	sched    schedStat
+
+	lead procStat // stat of the monitored process
+}
+
+func (u *usage) add(m *member) {
+	u.usr += m.stat.utime
+	u.sys += m.stat.stime
+	u.cusr += m.stat.cutime
+	u.csys += m.stat.cstime
+	u.vmem += m.stat.vsize
+	u.rss += m.stat.rss
+	u.nthreads += m.stat.nthreads
+	u.minflt += m.stat.minflt
+	u.majflt += m.stat.majflt
+	u.cminflt += m.stat.cminflt
+	u.cmajflt += m.stat.cmajflt
+	u.io.add(m.io)
+	u.status.add(m.status)
+	u.sched.add(m.sched)
+}
+
+func (u usage) infos() Infos {
+	return Infos{
+		CPU:      time.Duration((u.usr + u.sys) * clockTicksToNanosecond),
+		UTime:    time.Duration(u.usr * clockTicksToNanosecond),
+		STime:    time.Duration(u.sys * clockTicksToNanosecond),
+		VMem:     int64(u.vmem) / 1024,    // in kB
+		RSS:      u.rss * PageSize / 1024, // in kB
+		Threads:  u.nthreads,
+		Rchar:    u.io.rchar / 1024, // in kB
+		Wchar:    u.io.wchar / 1024, // in kB
+		Rdisk:    u.io.rdisk / 1024, // in kB
+		Wdisk:    u.io.wdisk / 1024, // in kB
+		RSSAnon:  u.status.rssAnon,
+		RSSFile:  u.status.rssFile,
+		RSSShmem: u.status.rssShmem,
+		Swap:     u.status.vmSwap,
+		RSSPeak:  u.status.vmHWM,
+		VMemPeak: u.status.vmPeak,
+
+		MinFlt:    int64(u.minflt),
+		MajFlt:    int64(u.majflt),
+		CMinFlt:   int64(u.cminflt),
+		CMajFlt:   int64(u.cmajflt),
+		CUTime:    time.Duration(u.cusr * int64(clockTicksToNanosecond)),
+		CSTime:    time.Duration(u.csys * int64(clockTicksToNanosecond)),
+		Priority:  u.lead.priority,
+		Nice:      u.lead.nice,
+		State:     u.lead.state,
+		StartTime: time.Duration(u.lead.starttime * int64(clockTicksToNanosecond)),
+
+		VolCtxSw:   u.status.volCtx,
+		InvolCtxSw: u.status.nvolCtx,
+		RunTime:    time.Duration(u.sched.run),
+		WaitTime:   time.Duration(u.sched.wait),
+		Timeslices: u.sched.slices,
+
+		Syscr:  u.io.syscr,
+		Syscw:  u.io.syscw,
+		Cwdisk: u.io.cdisk / 1024, // in kB
+	}
+}
+
+// zombie returns whether the provided member of the tree has exited, but
+// has not been reaped yet.
+func (c *collector) zombie(pid int, stats map[int]procStat) bool {
+	m, ok := c.procs[pid]
+	if !ok {
+		return false
+	}
+	stat, ok := stats[pid]
+	return ok && stat.state == 'Z' && stat.starttime == m.stat.starttime
+}
+
+// reaper returns the live (or zombie) member of the tree that reaped the
+// provided exited member, if any, by following its last known parents
+// through the other exited members.
+func (c *collector) reaper(m *member, pids map[int]struct{}, stats map[int]procStat) (int, bool) {
+	for range len(c.procs) {
+		ppid := m.stat.ppid
+		if _, ok := pids[ppid]; ok || c.zombie(ppid, stats) {
+			return ppid, true
+		}
+		var ok bool
+		m, ok = c.procs[ppid]
+		if !ok {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// members returns the set of live processes that belong to the monitored
+// process tree.
+// Zombies are not members: their resources are released, and their CPU
+// time is accounted for by their parent once reaped.
+func (c *collector) members(stats map[int]procStat) map[int]struct{} {
+	pids := make(map[int]struct{}, len(c.procs))
+	zombie := func(pid int, _ struct{}) bool {
+		return stats[pid].state == 'Z'
+	}
+
+	if _, ok := stats[c.pid]; ok && c.sel == nil {
+		pids[c.pid] = struct{}{}
+	}
+	for pid, m := range c.procs {
+		stat, ok := stats[pid]
+		if !ok || stat.starttime != m.stat.starttime {
+			// process exited (and its pid may have been recycled.)
+			continue
+		}
+		pids[pid] = struct{}{}
+	}
+	if c.subreaper {
+		// orphaned descendants of the command are adopted by the
+		// current process.
+		for _, pid := range c.orphans.update(stats) {
+			pids[pid] = struct{}{}
+		}
+	}
+
+	if c.sel != nil && c.rescan {
+		for pid, stat := range stats {
+			if _, ok := pids[pid]; ok {
+				continue
+			}
+			if c.sel.match(pid, stat) {
+				c.msg.Printf("selected pid=%d (%s)", pid, stat.comm)
+				pids[pid] = struct{}{}
+			}
+		}
+	}
+
+	if !c.descend {
+		maps.DeleteFunc(pids, zombie)
+		return pids
+	}
+
+	for pid, stat := range stats {
+		if stat.pgrp == c.pid && c.sel == nil {
+			// commands are started in their own process group.
+			pids[pid] = struct{}{}
+		}
+	}
+
+	// add descendants until the tree does not grow anymore.
+	for {
+		n := len(pids)
+		for pid, stat := range stats {
+			if _, ok := pids[pid]; ok {
+				continue
+			}
+			if _, ok := pids[stat.ppid]; ok {
+				pids[pid] = struct{}{}
+			}
+		}
+		if len(pids) == n {
+			maps.DeleteFunc(pids, zombie)
+			return pids
+		}
+	}
+}
+
+// scanProcs returns the stat of all the processes currently running.
+func scanProcs() (map[int]procStat, error) {
+	dirs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[int]procStat, len(dirs))
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := readStat(pid)
+		if err != nil {
+			// process may have exited in the meantime.
+			continue
+		}
+		stats[pid] = stat
+	}
+	return stats, nil
+}
+
+func readStat(pid int) (procStat, error) {
+	raw, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return procStat{}, err
+	}
+	return parseStat(raw)
+}
+
+func readStatus(pid int) (procStatus, error) {
+	raw, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return procStatus{}, err
+	}
+	return parseStatus(raw)
+}
+
+func readSchedStat(pid int) (schedStat, error) {
+	raw, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/schedstat")
+	if err != nil {
+		return schedStat{}, err
+	}
+	return parseSchedStat(raw)
+}
+
+// reread rewinds f and reads its whole content.
+func reread(f *os.File) ([]byte, error) {
+	_, err := f.Seek(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
+
+func readIO(pid int) (ioStat, error) {
+	f, err := os.Open("/proc/" + strconv.Itoa(pid) + "/io")
+	if err != nil {
+		return ioStat{}, err
+	}
+	defer f.Close()
+	return parseIO(f)
+}
+
+// collectThreads collects the state and CPU usage of all the threads of
+// the monitored processes, from /proc/<pid>/task/<tid>/{stat,comm}.
+func (c *collector) collectThreads() []ThreadInfos {
+	var threads []ThreadInfos
+	for _, pid := range c.pids() {
+		dir := "/proc/" + strconv.Itoa(pid) + "/task/"
+		tasks, err := os.ReadDir(dir)
+		if err != nil {
+			c.msg.Printf("could not read tasks of pid=%d: %+v", pid, err)
+			continue
+		}
+		for _, task := range tasks {
+			tid, err := strconv.Atoi(task.Name())
+			if err != nil {
+				continue
+			}
+			raw, err := os.ReadFile(dir + task.Name() + "/stat")
+			if err != nil {
+				// thread may have exited in the meantime.
+				continue
+			}
+			stat, err := parseStat(raw)
+			if err != nil {
+				c.msg.Printf("could not parse stat of tid=%d: %+v", tid, err)
+				continue
+			}
+			name := stat.comm
+			if comm, err := os.ReadFile(dir + task.Name() + "/comm"); err == nil {
+				name = string(bytes.TrimSpace(comm))
+			}
+			threads = append(threads, ThreadInfos{
+				TID:   tid,
+				Name:  name,
+				State: stat.state,
+				UTime: time.Duration(stat.utime * clockTicksToNanosecond),
+				STime: time.Duration(stat.stime * clockTicksToNanosecond),
+			})
+		}
+	}
+	return threads
+}
+
+// scanKV scans the "key: value [kB]" lines of a /proc file and stores the
+// values of the requested keys.
+// Values with a kB unit are stored in kB.
+func scanKV(data []byte, keys map[string]*int64) error {
+	for _, line := range strings.Split(string(data), "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		ptr, ok := keys[k]
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(v), "kB"))
+		if i := strings.IndexAny(v, " \t"); i >= 0 {
+			v = v[:i]
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse value of %q: %w", k, err)
+		}
+		*ptr = n
+	}
+	return nil
+}
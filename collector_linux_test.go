@@ -0,0 +1,210 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStat(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want procStat
+	}{
+		{
+			name: "cat",
+			data: "2565 (cat) R 2561 2565 2561 0 -1 4194304 82 0 3 1 7 2 -1 4 20 0 1 0 532323 2703360 309 18446744073709551615 94293998477312 94293998497193 140728884326880 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0 94293998513200 94293998514816 94294945329152 140728884331849 140728884331869 140728884331869 140728884334571 0\n",
+			want: procStat{
+				pid: 2565, comm: "cat", state: 'R',
+				ppid: 2561, pgrp: 2565, session: 2561,
+				tty: 0, tpgid: -1, flags: 4194304,
+				minflt: 82, cminflt: 0, majflt: 3, cmajflt: 1,
+				utime: 7, stime: 2, cutime: -1, cstime: 4,
+				priority: 20, nice: 0, nthreads: 1,
+				itrealval: 0, starttime: 532323,
+				vsize: 2703360, rss: 309,
+			},
+		},
+		{
+			name: "comm with spaces and parentheses",
+			data: "42 (tmux: (server) x) S 1 42 42 0 -1 4194624 1 2 3 4 5 6 7 8 20 0 3 0 100 4096 2 0\n",
+			want: procStat{
+				pid: 42, comm: "tmux: (server) x", state: 'S',
+				ppid: 1, pgrp: 42, session: 42,
+				tty: 0, tpgid: -1, flags: 4194624,
+				minflt: 1, cminflt: 2, majflt: 3, cmajflt: 4,
+				utime: 5, stime: 6, cutime: 7, cstime: 8,
+				priority: 20, nice: 0, nthreads: 3,
+				itrealval: 0, starttime: 100,
+				vsize: 4096, rss: 2,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseStat([]byte(tc.data))
+			if err != nil {
+				t.Fatalf("could not parse stat: %+v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("invalid stat:\ngot= %+v\nwant=%+v", got, tc.want)
+			}
+		})
+	}
+
+	for _, data := range []string{
+		"42 cat R 1\n",            // missing comm
+		"42 (cat) R 1 42 42 0 -1", // truncated
+		"x (cat) R 1 42 42 0 -1 0 0 0 0 0 0 0 0 0 20 0 1 0 0 0 0\n",
+	} {
+		_, err := parseStat([]byte(data))
+		if err == nil {
+			t.Fatalf("expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestParseIO(t *testing.T) {
+	const data = `rchar: 4292
+wchar: 0
+syscr: 13
+syscw: 2
+read_bytes: 8192
+write_bytes: 4096
+cancelled_write_bytes: 1024
+`
+	got, err := parseIO(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("could not parse io: %+v", err)
+	}
+	want := ioStat{
+		rchar: 4292, wchar: 0,
+		syscr: 13, syscw: 2,
+		rdisk: 8192, wdisk: 4096,
+		cdisk: 1024,
+	}
+	if got != want {
+		t.Fatalf("invalid io:\ngot= %+v\nwant=%+v", got, want)
+	}
+
+	_, err = parseIO(strings.NewReader("rchar: 4292\nwchar: 0\n"))
+	if err == nil {
+		t.Fatalf("expected an error parsing a truncated io file")
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	const data = `Name:	cat
+Umask:	0022
+State:	R (running)
+Pid:	2565
+VmPeak:	    3348 kB
+VmSize:	    3340 kB
+VmLck:	       0 kB
+VmHWM:	    1624 kB
+VmRSS:	    1624 kB
+RssAnon:	     132 kB
+RssFile:	    1492 kB
+RssShmem:	       4 kB
+VmData:	     292 kB
+VmSwap:	      16 kB
+Threads:	1
+voluntary_ctxt_switches:	3
+nonvoluntary_ctxt_switches:	5
+`
+	got, err := parseStatus([]byte(data))
+	if err != nil {
+		t.Fatalf("could not parse status: %+v", err)
+	}
+	want := procStatus{
+		rssAnon:  132,
+		rssFile:  1492,
+		rssShmem: 4,
+		vmSwap:   16,
+		vmHWM:    1624,
+		vmPeak:   3348,
+		volCtx:   3,
+		nvolCtx:  5,
+	}
+	if got != want {
+		t.Fatalf("invalid status:\ngot= %+v\nwant=%+v", got, want)
+	}
+}
+
+func TestParseSchedStat(t *testing.T) {
+	got, err := parseSchedStat([]byte("1288947 65895 12\n"))
+	if err != nil {
+		t.Fatalf("could not parse schedstat: %+v", err)
+	}
+	if want := (schedStat{run: 1288947, wait: 65895, slices: 12}); got != want {
+		t.Fatalf("invalid schedstat:\ngot= %+v\nwant=%+v", got, want)
+	}
+
+	_, err = parseSchedStat(nil)
+	if err == nil {
+		t.Fatalf("expected an error parsing an empty schedstat")
+	}
+}
+
+func TestScanKV(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		keys []string
+		want map[string]int64
+	}{
+		{
+			name: "meminfo",
+			data: "MemTotal:       16318456 kB\nMemFree:         1021440 kB\nMemAvailable:    9164644 kB\nHugePages_Total:       0\n",
+			keys: []string{"MemTotal", "MemAvailable", "HugePages_Total"},
+			want: map[string]int64{
+				"MemTotal":        16318456,
+				"MemAvailable":    9164644,
+				"HugePages_Total": 0,
+			},
+		},
+		{
+			name: "missing key",
+			data: "MemTotal: 42 kB\n",
+			keys: []string{"MemTotal", "MemFree"},
+			want: map[string]int64{"MemTotal": 42, "MemFree": -1},
+		},
+		{
+			name: "trailing fields",
+			data: "Pss:  120 kB extra\nNoColon 3\n",
+			keys: []string{"Pss"},
+			want: map[string]int64{"Pss": 120},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				got  = make(map[string]int64, len(tc.keys))
+				ptrs = make(map[string]*int64, len(tc.keys))
+			)
+			for _, k := range tc.keys {
+				v := int64(-1)
+				ptrs[k] = &v
+			}
+			err := scanKV([]byte(tc.data), ptrs)
+			if err != nil {
+				t.Fatalf("could not scan: %+v", err)
+			}
+			for k, v := range ptrs {
+				got[k] = *v
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("invalid values:\ngot= %v\nwant=%v", got, tc.want)
+			}
+		})
+	}
+
+	var v int64
+	err := scanKV([]byte("MemTotal: lots kB\n"), map[string]*int64{"MemTotal": &v})
+	if err == nil {
+		t.Fatalf("expected an error scanning an invalid value")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	sys "golang.org/x/sys/unix"
+)
+
+// readEnv returns the metadata of the host and of the pmon build.
+// Metadata that can not be read are left empty.
+func readEnv() EnvInfos {
+	env := EnvInfos{
+		CPUModel:   cpuModel(),
+		CPUs:       cpusOnline(),
+		MemTotal:   memTotal(),
+		PageSize:   PageSize,
+		ClockTicks: clockTicks(),
+		Version:    version(),
+		GoVersion:  runtime.Version(),
+	}
+	env.Hostname, _ = os.Hostname()
+
+	var uname sys.Utsname
+	if err := sys.Uname(&uname); err == nil {
+		env.Kernel = sys.ByteSliceToString(uname.Release[:])
+	}
+
+	return env
+}
+
+// version returns the version of the pmon module, as recorded in the build
+// informations of the running binary.
+func version() string {
+	const path = "github.com/sbinet/pmon"
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(unknown)"
+	}
+	if bi.Main.Path != path {
+		for _, dep := range bi.Deps {
+			if dep.Path == path {
+				return dep.Version
+			}
+		}
+		return "(unknown)"
+	}
+
+	v := bi.Main.Version
+	if v != "(devel)" && v != "" {
+		return v
+	}
+	// development build: identify it by its VCS revision, if any.
+	for _, s := range bi.Settings {
+		if s.Key == "vcs.revision" {
+			return "(devel) " + s.Value
+		}
+	}
+	return "(devel)"
+}
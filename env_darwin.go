@@ -0,0 +1,40 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"runtime"
+
+	sys "golang.org/x/sys/unix"
+)
+
+// cpuModel returns the model name of the CPUs.
+func cpuModel() string {
+	model, _ := sys.Sysctl("machdep.cpu.brand_string")
+	return model
+}
+
+// memTotal returns the total physical memory (kB).
+func memTotal() int64 {
+	total, err := sys.SysctlUint64("hw.memsize")
+	if err != nil {
+		return -1
+	}
+	return int64(total / 1024)
+}
+
+// cpusOnline returns the number of CPUs of the host.
+func cpusOnline() int {
+	n, err := sys.SysctlUint32("hw.logicalcpu")
+	if err != nil {
+		return runtime.NumCPU()
+	}
+	return int(n)
+}
+
+// clockTicks returns the number of clock ticks per second.
+func clockTicks() int64 {
+	return int64(ClockTicks)
+}
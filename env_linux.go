@@ -0,0 +1,121 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cpuModel returns the model name of the CPUs, from /proc/cpuinfo.
+func cpuModel() string {
+	raw, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "model name", "Model", "cpu model":
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// memTotal returns the total usable memory (kB), from /proc/meminfo.
+func memTotal() int64 {
+	var total int64 = -1
+	if raw, err := os.ReadFile("/proc/meminfo"); err == nil {
+		_ = scanKV(raw, map[string]*int64{"MemTotal": &total})
+	}
+	return total
+}
+
+// cpusOnline returns the number of online CPUs of the host, from
+// /sys/devices/system/cpu/online, or the number of CPUs usable by pmon if
+// it can not be read.
+func cpusOnline() int {
+	raw, err := os.ReadFile("/sys/devices/system/cpu/online")
+	if err != nil {
+		return runtime.NumCPU()
+	}
+	n, err := parseCPUList(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return runtime.NumCPU()
+	}
+	return n
+}
+
+// parseCPUList returns the number of CPUs of a CPU list, such as "0-3,5".
+func parseCPUList(list string) (int, error) {
+	n := 0
+	for _, v := range strings.Split(list, ",") {
+		lo, hi, ok := strings.Cut(v, "-")
+		beg, err := strconv.Atoi(lo)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse CPU list %q: %w", list, err)
+		}
+		end := beg
+		if ok {
+			end, err = strconv.Atoi(hi)
+			if err != nil {
+				return 0, fmt.Errorf("could not parse CPU list %q: %w", list, err)
+			}
+		}
+		if end < beg {
+			return 0, fmt.Errorf("invalid CPU range %q", v)
+		}
+		n += end - beg + 1
+	}
+	return n, nil
+}
+
+// clockTicks returns the number of clock ticks per second, as passed by the
+// kernel in the AT_CLKTCK entry of the auxiliary vector, or ClockTicks if
+// it can not be read.
+// The times of /proc/<pid>/stat are converted with it.
+func clockTicks() int64 {
+	raw, err := os.ReadFile("/proc/self/auxv")
+	if err != nil {
+		return int64(ClockTicks)
+	}
+	if v, ok := auxv(raw, atClkTck); ok && v > 0 {
+		return int64(v)
+	}
+	return int64(ClockTicks)
+}
+
+// atClkTck is the type of the auxiliary vector entry holding the frequency
+// of times().
+const atClkTck = 17
+
+// auxv returns the value of the entry of the provided type, from the content
+// of /proc/<pid>/auxv: a list of (type, value) pairs of native words.
+func auxv(raw []byte, typ uint64) (uint64, bool) {
+	word := func(b []byte) uint64 {
+		if strconv.IntSize == 32 {
+			return uint64(binary.NativeEndian.Uint32(b))
+		}
+		return binary.NativeEndian.Uint64(b)
+	}
+
+	sz := strconv.IntSize / 8
+	for len(raw) >= 2*sz {
+		k, v := word(raw[:sz]), word(raw[sz:2*sz])
+		if k == typ {
+			return v, true
+		}
+		raw = raw[2*sz:]
+	}
+	return 0, false
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"encoding/binary"
+	"strconv"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	for _, tc := range []struct {
+		list string
+		want int
+	}{
+		{"0", 1},
+		{"0-3", 4},
+		{"0-3,5", 5},
+		{"0,2,4-7,9-10", 8},
+	} {
+		got, err := parseCPUList(tc.list)
+		if err != nil {
+			t.Fatalf("could not parse CPU list %q: %+v", tc.list, err)
+		}
+		if got != tc.want {
+			t.Fatalf("invalid number of CPUs of %q: got=%d, want=%d", tc.list, got, tc.want)
+		}
+	}
+
+	for _, list := range []string{"", "3-1", "0-x"} {
+		_, err := parseCPUList(list)
+		if err == nil {
+			t.Fatalf("expected an error parsing %q", list)
+		}
+	}
+}
+
+func TestAuxv(t *testing.T) {
+	words := func(vs ...uint64) []byte {
+		var raw []byte
+		for _, v := range vs {
+			if strconv.IntSize == 32 {
+				raw = binary.NativeEndian.AppendUint32(raw, uint32(v))
+				continue
+			}
+			raw = binary.NativeEndian.AppendUint64(raw, v)
+		}
+		return raw
+	}
+
+	raw := words(
+		33, 0x7ffc0000, // AT_SYSINFO_EHDR
+		6, 4096, // AT_PAGESZ
+		atClkTck, 250,
+		0, 0, // AT_NULL
+	)
+	for _, tc := range []struct {
+		raw  []byte
+		typ  uint64
+		want uint64
+		ok   bool
+	}{
+		{raw: raw, typ: atClkTck, want: 250, ok: true},
+		{raw: raw, typ: 6, want: 4096, ok: true},
+		{raw: raw, typ: 25},
+		{raw: raw[:len(raw)/2], typ: atClkTck},
+		{raw: nil, typ: atClkTck},
+	} {
+		got, ok := auxv(tc.raw, tc.typ)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("invalid auxv entry %d: got=(%d, %v), want=(%d, %v)", tc.typ, got, ok, tc.want, tc.ok)
+		}
+	}
+}
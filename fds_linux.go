@@ -0,0 +1,185 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// collectFDs collects the inventory of the file descriptors opened by the
+// monitored processes, from /proc/<pid>/fd.
+// Sockets are joined with /proc/<pid>/net/{tcp,tcp6,udp,udp6} to count
+// connections per protocol and state.
+// RLIMIT_NOFILE being a per-process limit, the number of file descriptors of
+// the process closest to its limit is reported alongside that limit.
+func (c *collector) collectFDs() (*FDInfos, []SockInfos) {
+	var (
+		fds   FDInfos
+		socks = make(map[[2]string]int64)
+		usage = -1.0 // fraction of its limit used by the process closest to it
+	)
+	for _, pid := range c.pids() {
+		dir := "/proc/" + strconv.Itoa(pid) + "/fd/"
+		ents, err := os.ReadDir(dir)
+		if err != nil {
+			c.msg.Printf("could not read fds of pid=%d: %+v", pid, err)
+			continue
+		}
+
+		var (
+			inodes []string
+			n      int64
+		)
+		for _, ent := range ents {
+			link, err := os.Readlink(dir + ent.Name())
+			if err != nil {
+				// fd may have been closed in the meantime.
+				continue
+			}
+			n++
+			fds.Total++
+			switch {
+			case strings.HasPrefix(link, "socket:["):
+				fds.Sockets++
+				inodes = append(inodes, strings.TrimSuffix(link[len("socket:["):], "]"))
+			case strings.HasPrefix(link, "pipe:["):
+				fds.Pipes++
+			case link == "anon_inode:[eventfd]":
+				fds.EventFDs++
+			case strings.HasPrefix(link, "anon_inode:"):
+				fds.AnonInodes++
+			default:
+				fi, err := os.Stat(dir + ent.Name())
+				if err == nil && fi.Mode().IsRegular() {
+					fds.Files++
+					continue
+				}
+				fds.Others++
+			}
+		}
+
+		limit, err := readNoFile(pid)
+		if err != nil {
+			c.msg.Printf("could not read fd limit of pid=%d: %+v", pid, err)
+			limit = -1
+		}
+		frac := 0.0
+		if limit > 0 {
+			frac = float64(n) / float64(limit)
+		}
+		if frac > usage || (frac == usage && n > fds.Peak) {
+			usage = frac
+			fds.Peak = n
+			fds.Limit = limit
+		}
+
+		if len(inodes) == 0 {
+			continue
+		}
+		conns := readSockets(pid)
+		for _, inode := range inodes {
+			key, ok := conns[inode]
+			if !ok {
+				key = [2]string{"other", "-"}
+			}
+			socks[key]++
+		}
+	}
+
+	if usage < 0 {
+		fds.Limit = -1
+	}
+
+	var out []SockInfos
+	for k, n := range socks {
+		out = append(out, SockInfos{Proto: k[0], State: k[1], N: n})
+	}
+	slices.SortFunc(out, func(a, b SockInfos) int {
+		if o := cmp.Compare(a.Proto, b.Proto); o != 0 {
+			return o
+		}
+		return cmp.Compare(a.State, b.State)
+	})
+
+	return &fds, out
+}
+
+// tcpStates holds the names of the TCP states, as defined in the Linux
+// kernel include/net/tcp_states.h.
+var tcpStates = [...]string{
+	1:  "ESTABLISHED",
+	2:  "SYN_SENT",
+	3:  "SYN_RECV",
+	4:  "FIN_WAIT1",
+	5:  "FIN_WAIT2",
+	6:  "TIME_WAIT",
+	7:  "CLOSE",
+	8:  "CLOSE_WAIT",
+	9:  "LAST_ACK",
+	10: "LISTEN",
+	11: "CLOSING",
+	12: "NEW_SYN_RECV",
+}
+
+// readSockets returns the protocol and state of the internet sockets
+// visible from the network namespace of the provided process, indexed by
+// socket inode.
+func readSockets(pid int) map[string][2]string {
+	conns := make(map[string][2]string)
+	for _, proto := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		f, err := os.Open("/proc/" + strconv.Itoa(pid) + "/net/" + proto)
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		sc.Scan() // skip header
+		for sc.Scan() {
+			// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+			fields := strings.Fields(sc.Text())
+			if len(fields) < 10 {
+				continue
+			}
+			st, err := strconv.ParseUint(fields[3], 16, 8)
+			state := fields[3]
+			if err == nil && int(st) < len(tcpStates) && tcpStates[st] != "" {
+				state = tcpStates[st]
+			}
+			conns[fields[9]] = [2]string{strings.TrimSuffix(proto, "6"), state}
+		}
+		f.Close()
+	}
+	return conns
+}
+
+// readNoFile returns the soft limit on the number of open files of the
+// provided process, from /proc/<pid>/limits.
+// Unlimited limits are reported as -1.
+func readNoFile(pid int) (int64, error) {
+	raw, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/limits")
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		v, ok := strings.CutPrefix(line, "Max open files")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(v)
+		if len(fields) == 0 {
+			break
+		}
+		if fields[0] == "unlimited" {
+			return -1, nil
+		}
+		return strconv.ParseInt(fields[0], 10, 64)
+	}
+	return 0, fmt.Errorf("could not find open files limit")
+}
@@ -0,0 +1,155 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// collectHost collects system-wide resources usage, from /proc/loadavg,
+// /proc/meminfo, /proc/stat, /proc/diskstats and /proc/net/dev.
+func (c *collector) collectHost() *HostInfos {
+	var host HostInfos
+
+	raw, err := os.ReadFile("/proc/loadavg")
+	if err == nil {
+		_, err = fmt.Sscanf(string(raw), "%f %f %f", &host.Load1, &host.Load5, &host.Load15)
+	}
+	if err != nil {
+		c.msg.Printf("could not read load average: %+v", err)
+	}
+
+	raw, err = os.ReadFile("/proc/meminfo")
+	if err == nil {
+		err = scanKV(raw, map[string]*int64{
+			"MemAvailable": &host.MemAvailable,
+			"Cached":       &host.Cached,
+			"Dirty":        &host.Dirty,
+			"Writeback":    &host.Writeback,
+			"SwapFree":     &host.SwapFree,
+		})
+	}
+	if err != nil {
+		c.msg.Printf("could not read memory informations: %+v", err)
+	}
+
+	err = readCPUStat(&host)
+	if err != nil {
+		c.msg.Printf("could not read CPU statistics: %+v", err)
+	}
+
+	err = readDiskStats(&host)
+	if err != nil {
+		c.msg.Printf("could not read disk statistics: %+v", err)
+	}
+
+	err = readNetDev(&host)
+	if err != nil {
+		c.msg.Printf("could not read network statistics: %+v", err)
+	}
+
+	return &host
+}
+
+// readCPUStat reads the total CPU times from /proc/stat.
+func readCPUStat(host *HostInfos) error {
+	raw, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return err
+	}
+	line, _, _ := strings.Cut(string(raw), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 9 || fields[0] != "cpu" {
+		return fmt.Errorf("invalid /proc/stat cpu line %q", line)
+	}
+
+	// cpu user nice system idle iowait irq softirq steal [guest guest_nice]
+	var ticks [8]uint64
+	for i := range ticks {
+		ticks[i], err = strconv.ParseUint(fields[i+1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse /proc/stat cpu line %q: %w", line, err)
+		}
+	}
+	var (
+		busy  = ticks[0] + ticks[1] + ticks[2] + ticks[5] + ticks[6]
+		total = busy + ticks[3] + ticks[4] + ticks[7]
+	)
+	host.Busy = time.Duration(busy * clockTicksToNanosecond)
+	host.IOWait = time.Duration(ticks[4] * clockTicksToNanosecond)
+	host.Steal = time.Duration(ticks[7] * clockTicksToNanosecond)
+	host.Total = time.Duration(total * clockTicksToNanosecond)
+	return nil
+}
+
+// readDiskStats reads the number of bytes read from and written to the
+// disks of the system, from /proc/diskstats.
+// Partitions and other devices that are not listed under /sys/block are
+// skipped, so their I/O is not counted twice.
+func readDiskStats(host *HostInfos) error {
+	const sectorSize = 512
+
+	raw, err := os.ReadFile("/proc/diskstats")
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		// major minor name reads merged sectors-read ms writes merged sectors-written ...
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+		if _, err := os.Stat("/sys/block/" + fields[2]); err != nil {
+			continue
+		}
+		rsec, err := strconv.ParseInt(fields[5], 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse diskstats line %q: %w", line, err)
+		}
+		wsec, err := strconv.ParseInt(fields[9], 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse diskstats line %q: %w", line, err)
+		}
+		host.Rdisk += rsec * sectorSize / 1024 // in kB
+		host.Wdisk += wsec * sectorSize / 1024 // in kB
+	}
+	return nil
+}
+
+// readNetDev reads the number of bytes received and transmitted by the
+// network interfaces of the system (except the loopback one), from
+// /proc/net/dev.
+func readNetDev(host *HostInfos) error {
+	raw, err := os.ReadFile("/proc/net/dev")
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		// iface: rx-bytes rx-packets ... (8 fields) tx-bytes ...
+		iface, stats, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(iface) == "lo" {
+			continue
+		}
+		fields := strings.Fields(stats)
+		if len(fields) < 9 {
+			continue
+		}
+		rx, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse net/dev line %q: %w", line, err)
+		}
+		tx, err := strconv.ParseInt(fields[8], 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse net/dev line %q: %w", line, err)
+		}
+		host.NetRx += rx / 1024 // in kB
+		host.NetTx += tx / 1024 // in kB
+	}
+	return nil
+}
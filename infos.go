@@ -6,14 +6,22 @@ package pmon
 
 import (
 	"bufio"
+	"cmp"
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"time"
+
+	sys "golang.org/x/sys/unix"
 )
 
 // Infos holds monitoring informations gathered during monitoring.
+//
+// In tree mode, CPU, UTime and STime include the time of the exited
+// descendants of the monitored process(es), so that the CPU usage of
+// short-lived children is not lost.
 type Infos struct {
 	CPU     time.Duration `json:"cpu"`      // user+system time (ms)
 	UTime   time.Duration `json:"usr"`      // user time (ms)
@@ -25,6 +33,451 @@ type Infos struct {
 	Wchar   int64         `json:"wchar"`    // number of bytes written to storage (kB)
 	Rdisk   int64         `json:"rdisk"`    // number of bytes read from physical storage (kB)
 	Wdisk   int64         `json:"wdisk"`    // number of bytes written to physical storage (kB)
+
+	RSSAnon  int64 `json:"rss_anon"`  // resident anonymous memory (kB)
+	RSSFile  int64 `json:"rss_file"`  // resident file mappings (kB)
+	RSSShmem int64 `json:"rss_shmem"` // resident shared memory (kB)
+	Swap     int64 `json:"swap"`      // swapped-out virtual memory (kB)
+	RSSPeak  int64 `json:"rss_peak"`  // peak resident set size, as recorded by the kernel, or largest sampled one in tree mode (kB)
+	VMemPeak int64 `json:"vmem_peak"` // peak virtual memory, as recorded by the kernel, or largest sampled one in tree mode (kB)
+
+	MinFlt    int64         `json:"minflt"`   // number of minor page faults
+	MajFlt    int64         `json:"majflt"`   // number of major page faults (which required loading a page from disk)
+	CMinFlt   int64         `json:"cminflt"`  // number of minor page faults of the waited-for children
+	CMajFlt   int64         `json:"cmajflt"`  // number of major page faults of the waited-for children
+	CUTime    time.Duration `json:"cusr"`     // user time of the waited-for children (ms)
+	CSTime    time.Duration `json:"csys"`     // system time of the waited-for children (ms)
+	Priority  int64         `json:"priority"` // scheduling priority
+	Nice      int64         `json:"nice"`     // nice value
+	State     byte          `json:"state"`    // process state (R, S, D, Z, ...)
+	StartTime time.Duration `json:"start"`    // start time of the process, since system boot (ms)
+
+	VolCtxSw   int64         `json:"vol_ctxsw"`   // number of voluntary context switches
+	InvolCtxSw int64         `json:"invol_ctxsw"` // number of involuntary context switches
+	RunTime    time.Duration `json:"run"`         // time spent running on a CPU (ms)
+	WaitTime   time.Duration `json:"wait"`        // time spent waiting on a run-queue (ms)
+	Timeslices int64         `json:"timeslices"`  // number of timeslices run on a CPU (-1 without scheduler statistics)
+
+	Syscr  int64 `json:"syscr"`  // number of read syscalls
+	Syscw  int64 `json:"syscw"`  // number of write syscalls
+	Cwdisk int64 `json:"cwdisk"` // number of bytes whose writing to physical storage was cancelled (kB)
+}
+
+// infosFmt is the format of an Infos sample in a pmon log file.
+// Columns are only ever appended, so logs written by older versions of
+// pmon can still be parsed.
+const infosFmt = "%f %f %f %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %d %f %f %d %d %c %f %d %d %f %f %d %d %d %d"
+
+// args returns the values of the columns of an Infos sample.
+func (v *Infos) args() []any {
+	return []any{
+		milliseconds(v.CPU), milliseconds(v.UTime), milliseconds(v.STime),
+		v.VMem, v.RSS,
+		v.Threads,
+		v.Rchar, v.Wchar,
+		v.Rdisk, v.Wdisk,
+		v.RSSAnon, v.RSSFile, v.RSSShmem,
+		v.Swap,
+		v.RSSPeak, v.VMemPeak,
+		v.MinFlt, v.MajFlt, v.CMinFlt, v.CMajFlt,
+		milliseconds(v.CUTime), milliseconds(v.CSTime),
+		v.Priority, v.Nice,
+		cmp.Or(v.State, '-'),
+		milliseconds(v.StartTime),
+		v.VolCtxSw, v.InvolCtxSw,
+		milliseconds(v.RunTime), milliseconds(v.WaitTime),
+		v.Timeslices,
+		v.Syscr, v.Syscw, v.Cwdisk,
+	}
+}
+
+// parseInfos parses an Infos sample.
+// Columns missing from samples written by older versions of pmon are left
+// to their zero value.
+func parseInfos(txt string) (Infos, error) {
+	const ncols = 10 // number of columns of the original format
+
+	var (
+		v     Infos
+		cpu   float64
+		usr   float64
+		sys   float64
+		cusr  float64
+		csys  float64
+		start float64
+		run   float64
+		wait  float64
+
+		ptrs = []any{
+			&cpu, &usr, &sys,
+			&v.VMem, &v.RSS,
+			&v.Threads,
+			&v.Rchar, &v.Wchar,
+			&v.Rdisk, &v.Wdisk,
+			&v.RSSAnon, &v.RSSFile, &v.RSSShmem,
+			&v.Swap,
+			&v.RSSPeak, &v.VMemPeak,
+			&v.MinFlt, &v.MajFlt, &v.CMinFlt, &v.CMajFlt,
+			&cusr, &csys,
+			&v.Priority, &v.Nice,
+			&v.State,
+			&start,
+			&v.VolCtxSw, &v.InvolCtxSw,
+			&run, &wait,
+			&v.Timeslices,
+			&v.Syscr, &v.Syscw, &v.Cwdisk,
+		}
+		verbs  = strings.Fields(infosFmt)
+		fields = strings.Fields(txt)
+	)
+
+	if len(fields) < ncols {
+		return v, fmt.Errorf("invalid number of columns (got=%d, want>=%d)", len(fields), ncols)
+	}
+
+	for i, field := range fields[:min(len(fields), len(ptrs))] {
+		_, err := fmt.Sscanf(field, verbs[i], ptrs[i])
+		if err != nil {
+			return v, fmt.Errorf("could not scan column %d: %w", i, err)
+		}
+	}
+
+	v.CPU = fromMilliseconds(cpu)
+	v.UTime = fromMilliseconds(usr)
+	v.STime = fromMilliseconds(sys)
+	v.CUTime = fromMilliseconds(cusr)
+	v.CSTime = fromMilliseconds(csys)
+	v.StartTime = fromMilliseconds(start)
+	v.RunTime = fromMilliseconds(run)
+	v.WaitTime = fromMilliseconds(wait)
+	if v.State == '-' {
+		// unknown state, written as '-'.
+		v.State = 0
+	}
+
+	return v, nil
+}
+
+// ProcInfos holds monitoring informations about a single process of
+// the monitored process tree.
+type ProcInfos struct {
+	Tick  int           `json:"tick"`  // index of the corresponding Infos sample
+	PID   int           `json:"pid"`   // process ID
+	PPID  int           `json:"ppid"`  // parent process ID
+	Comm  string        `json:"comm"`  // filename of the executable
+	CPU   time.Duration `json:"cpu"`   // user+system time (ms)
+	RSS   int64         `json:"rss"`   // resident set size (kB)
+	Rchar int64         `json:"rchar"` // number of bytes read from storage (kB)
+	Wchar int64         `json:"wchar"` // number of bytes written to storage (kB)
+	Rdisk int64         `json:"rdisk"` // number of bytes read from physical storage (kB)
+	Wdisk int64         `json:"wdisk"` // number of bytes written to physical storage (kB)
+}
+
+// ThreadInfos holds monitoring informations about a single thread of
+// the monitored process(es).
+type ThreadInfos struct {
+	Tick  int           `json:"tick"`  // index of the corresponding Infos sample
+	TID   int           `json:"tid"`   // thread ID
+	Name  string        `json:"name"`  // name of the thread
+	State byte          `json:"state"` // scheduler state of the thread (R, S, D, ...)
+	UTime time.Duration `json:"usr"`   // user time (ms)
+	STime time.Duration `json:"sys"`   // system time (ms)
+}
+
+// SmapsInfos holds the proportional and unique memory usage of the
+// monitored process(es).
+type SmapsInfos struct {
+	Tick    int   `json:"tick"`     // index of the corresponding Infos sample
+	PSS     int64 `json:"pss"`      // proportional set size (kB)
+	USS     int64 `json:"uss"`      // unique set size: private clean+dirty pages (kB)
+	Swap    int64 `json:"swap"`     // swapped out memory (kB)
+	SwapPSS int64 `json:"swap_pss"` // proportional swapped out memory (kB)
+}
+
+// MapsInfos holds the memory usage of a group of memory mappings of the
+// monitored process(es).
+type MapsInfos struct {
+	Tick     int    `json:"tick"`      // index of the corresponding Infos sample
+	Path     string `json:"path"`      // path of the mapping ([heap], [stack], [anon], file name, ...)
+	RSS      int64  `json:"rss"`       // resident set size (kB)
+	PSS      int64  `json:"pss"`       // proportional set size (kB)
+	Private  int64  `json:"private"`   // private clean+dirty pages (kB)
+	AnonHuge int64  `json:"anon_huge"` // anonymous memory backed by transparent huge pages (kB)
+}
+
+// FDInfos holds the inventory of the file descriptors opened by the
+// monitored process(es).
+type FDInfos struct {
+	Tick       int   `json:"tick"`        // index of the corresponding Infos sample
+	Total      int64 `json:"total"`       // total number of file descriptors
+	Files      int64 `json:"files"`       // number of regular files
+	Pipes      int64 `json:"pipes"`       // number of pipes
+	Sockets    int64 `json:"sockets"`     // number of sockets
+	EventFDs   int64 `json:"eventfds"`    // number of eventfds
+	AnonInodes int64 `json:"anon_inodes"` // number of other anonymous inodes (epoll, timerfd, ...)
+	Others     int64 `json:"others"`      // number of other file descriptors (devices, directories, ...)
+	Peak       int64 `json:"peak"`        // number of file descriptors of the process closest to its limit
+	Limit      int64 `json:"limit"`       // soft limit on the number of open files (RLIMIT_NOFILE) of that process, -1 if unlimited or unknown
+}
+
+// SockInfos holds the number of sockets of the monitored process(es) with
+// a given protocol and state.
+type SockInfos struct {
+	Tick  int    `json:"tick"`  // index of the corresponding Infos sample
+	Proto string `json:"proto"` // protocol (tcp, udp or other)
+	State string `json:"state"` // state of the connection (ESTABLISHED, CLOSE_WAIT, ...)
+	N     int64  `json:"n"`     // number of sockets
+}
+
+// FileInfos holds the position of a monitored process in one of the
+// regular files it has opened.
+type FileInfos struct {
+	Tick int    `json:"tick"` // index of the corresponding Infos sample
+	PID  int    `json:"pid"`  // process ID
+	FD   int    `json:"fd"`   // file descriptor
+	Pos  int64  `json:"pos"`  // read/write offset in the file (bytes)
+	Size int64  `json:"size"` // size of the file (bytes)
+	Mode string `json:"mode"` // access mode of the file (r, w or rw)
+	Path string `json:"path"` // path of the file
+}
+
+// CgroupInfos holds the metrics of the cgroup v2 of the monitored process.
+type CgroupInfos struct {
+	Tick        int           `json:"tick"`         // index of the corresponding Infos sample
+	Path        string        `json:"path"`         // path of the cgroup, relative to the cgroup v2 mount point
+	Memory      int64         `json:"memory"`       // total memory usage (kB)
+	Anon        int64         `json:"anon"`         // anonymous memory usage (kB)
+	File        int64         `json:"file"`         // page cache memory usage (kB)
+	Kernel      int64         `json:"kernel"`       // kernel memory usage (kB)
+	Sock        int64         `json:"sock"`         // network transmission buffers memory usage (kB)
+	High        int64         `json:"high"`         // number of times the memory usage was throttled over memory.high
+	OOM         int64         `json:"oom"`          // number of times the memory usage reached memory.max
+	OOMKill     int64         `json:"oom_kill"`     // number of processes killed by the OOM killer
+	CPU         time.Duration `json:"cpu"`          // total CPU time (ms)
+	NrThrottled int64         `json:"nr_throttled"` // number of throttled periods
+	Throttled   time.Duration `json:"throttled"`    // total throttled time (ms)
+	Rdisk       int64         `json:"rdisk"`        // number of bytes read from physical storage (kB)
+	Wdisk       int64         `json:"wdisk"`        // number of bytes written to physical storage (kB)
+	Rios        int64         `json:"rios"`         // number of read I/O operations
+	Wios        int64         `json:"wios"`         // number of write I/O operations
+}
+
+// PSIInfos holds the pressure stall information of a resource.
+type PSIInfos struct {
+	Tick      int           `json:"tick"`       // index of the corresponding Infos sample
+	Source    string        `json:"source"`     // source of the information (host or cgroup)
+	Resource  string        `json:"resource"`   // stalled resource (cpu, memory or io)
+	SomeAvg10 float64       `json:"some_avg10"` // percentage of time some tasks were stalled, over the last 10s
+	SomeTotal time.Duration `json:"some_total"` // total time some tasks were stalled (ms)
+	FullAvg10 float64       `json:"full_avg10"` // percentage of time all non-idle tasks were stalled, over the last 10s
+	FullTotal time.Duration `json:"full_total"` // total time all non-idle tasks were stalled (ms)
+}
+
+// HostInfos holds system-wide resources usage.
+type HostInfos struct {
+	Tick         int           `json:"tick"`          // index of the corresponding Infos sample
+	Load1        float64       `json:"load1"`         // load average over the last minute
+	Load5        float64       `json:"load5"`         // load average over the last 5 minutes
+	Load15       float64       `json:"load15"`        // load average over the last 15 minutes
+	MemAvailable int64         `json:"mem_available"` // memory available for starting new applications (kB)
+	Cached       int64         `json:"cached"`        // page cache memory (kB)
+	Dirty        int64         `json:"dirty"`         // memory waiting to be written back to disk (kB)
+	Writeback    int64         `json:"writeback"`     // memory being written back to disk (kB)
+	SwapFree     int64         `json:"swap_free"`     // free swap space (kB)
+	Busy         time.Duration `json:"busy"`          // total CPU time spent in user, nice, system, irq and softirq modes (ms)
+	IOWait       time.Duration `json:"iowait"`        // total CPU time spent waiting for I/O (ms)
+	Steal        time.Duration `json:"steal"`         // total CPU time stolen by the hypervisor (ms)
+	Total        time.Duration `json:"total"`         // total CPU time, including idle (ms)
+	Rdisk        int64         `json:"rdisk"`         // number of bytes read from disks (kB)
+	Wdisk        int64         `json:"wdisk"`         // number of bytes written to disks (kB)
+	NetRx        int64         `json:"net_rx"`        // number of bytes received by network interfaces (kB)
+	NetTx        int64         `json:"net_tx"`        // number of bytes transmitted by network interfaces (kB)
+}
+
+// StateInfos holds the number of threads of the monitored process(es) in
+// each scheduler state.
+type StateInfos struct {
+	Tick     int   `json:"tick"`     // index of the corresponding Infos sample
+	Running  int64 `json:"running"`  // number of running or runnable threads (R)
+	Sleeping int64 `json:"sleeping"` // number of threads in interruptible sleep (S)
+	Disk     int64 `json:"disk"`     // number of threads in uninterruptible sleep, usually I/O (D)
+	Other    int64 `json:"other"`    // number of threads in any other state (stopped, zombie, idle, ...)
+}
+
+// WchanInfos holds the number of threads of the monitored process(es)
+// waiting in a given kernel wait channel.
+type WchanInfos struct {
+	Tick int    `json:"tick"` // index of the corresponding Infos sample
+	Name string `json:"name"` // name of the kernel wait channel
+	N    int64  `json:"n"`    // number of threads
+}
+
+// SyscallInfos holds the number of threads of the monitored process(es)
+// blocked in a given syscall.
+type SyscallInfos struct {
+	Tick int    `json:"tick"` // index of the corresponding Infos sample
+	Nr   int    `json:"nr"`   // syscall number
+	Name string `json:"name"` // syscall name
+	N    int64  `json:"n"`    // number of threads
+}
+
+// StraceInfos holds the number of calls, errors and time spent in a given
+// syscall by the traced process(es), over a sampling period.
+type StraceInfos struct {
+	Tick   int           `json:"tick"`   // index of the corresponding Infos sample
+	Nr     int           `json:"nr"`     // syscall number
+	Name   string        `json:"name"`   // syscall name
+	Calls  int64         `json:"calls"`  // number of calls
+	Errors int64         `json:"errors"` // number of failed calls
+	Time   time.Duration `json:"time"`   // time spent in the syscall (ms)
+}
+
+// EventInfos holds a lifecycle event of the traced process(es), or the
+// OOM kill of the monitored command.
+type EventInfos struct {
+	Tick   int           `json:"tick"`   // index of the corresponding Infos sample
+	Time   time.Duration `json:"time"`   // time of the event, since the start of the monitoring (ms)
+	Kind   string        `json:"kind"`   // kind of event: fork, vfork, clone, exec, exit, killed, signal or oom-killed
+	PID    int           `json:"pid"`    // id of the process (or thread) subject of the event
+	Value  int           `json:"value"`  // parent id (fork, vfork, clone), exit code (exit), signal number (killed, signal) or RSS at death in kB (oom-killed)
+	Detail string        `json:"detail"` // command line arguments (exec), signal name (killed, signal) or memory limit that was hit, prefixed with "(probable) " if the OOM kill may have hit another process (oom-killed)
+}
+
+// EnvInfos holds the metadata of the host and of the pmon build of a run.
+type EnvInfos struct {
+	Hostname   string `json:"hostname"`    // name of the host
+	Kernel     string `json:"kernel"`      // kernel release
+	CPUModel   string `json:"cpu_model"`   // model name of the CPUs
+	CPUs       int    `json:"cpus"`        // number of online CPUs of the host
+	MemTotal   int64  `json:"mem_total"`   // total usable memory (kB)
+	PageSize   int64  `json:"page_size"`   // memory page size (bytes)
+	ClockTicks int64  `json:"clock_ticks"` // number of clock ticks per second of the kernel
+	Version    string `json:"version"`     // version of pmon
+	GoVersion  string `json:"go_version"`  // version of Go pmon was built with
+}
+
+// TargetInfos holds the metadata of a process monitored in attach mode.
+type TargetInfos struct {
+	PID        int       `json:"pid"`        // process id
+	Argv       []string  `json:"argv"`       // command line arguments
+	Exe        string    `json:"exe"`        // path of the executable
+	Cwd        string    `json:"cwd"`        // current working directory
+	UID        int       `json:"uid"`        // real user id
+	EUID       int       `json:"euid"`       // effective user id
+	GID        int       `json:"gid"`        // real group id
+	EGID       int       `json:"egid"`       // effective group id
+	Started    time.Time `json:"started"`    // start time of the process
+	Cgroup     string    `json:"cgroup"`     // cgroup v2 path of the process
+	Namespaces []string  `json:"namespaces"` // namespaces of the process, as "name:[inode]"
+}
+
+// ExitInfos holds the exit status and resources usage of the monitored
+// command.
+type ExitInfos struct {
+	Code     int    `json:"code"`      // exit code, or -1 if the command was killed by a signal
+	Signal   string `json:"signal"`    // name of the signal that killed the command, if any
+	CoreDump bool   `json:"core_dump"` // whether the command dumped core
+	Rusage   Rusage `json:"rusage"`    // resources usage of the command and its waited-for descendants
+}
+
+// Status returns the exit status of the command, in the fashion of a shell:
+// the exit code, or 128 plus the number of the signal that killed the
+// command.
+func (e ExitInfos) Status() int {
+	if e.Code >= 0 {
+		return e.Code
+	}
+	return 128 + int(sys.SignalNum(e.Signal))
+}
+
+func (e ExitInfos) String() string {
+	if e.Code >= 0 {
+		return "exit status " + strconv.Itoa(e.Code)
+	}
+	msg := "signal: " + e.Signal
+	if e.CoreDump {
+		msg += " (core dumped)"
+	}
+	return msg
+}
+
+// Rusage holds the resources usage of a process, as reported by wait4(2).
+type Rusage struct {
+	UTime    time.Duration `json:"utime"`    // user CPU time
+	STime    time.Duration `json:"stime"`    // system CPU time
+	MaxRSS   int64         `json:"maxrss"`   // maximum resident set size (kB)
+	IxRSS    int64         `json:"ixrss"`    // integral shared memory size
+	IdRSS    int64         `json:"idrss"`    // integral unshared data size
+	IsRSS    int64         `json:"isrss"`    // integral unshared stack size
+	MinFlt   int64         `json:"minflt"`   // number of minor page faults
+	MajFlt   int64         `json:"majflt"`   // number of major page faults
+	NSwap    int64         `json:"nswap"`    // number of swaps
+	InBlock  int64         `json:"inblock"`  // number of block input operations
+	OuBlock  int64         `json:"oublock"`  // number of block output operations
+	MsgSnd   int64         `json:"msgsnd"`   // number of IPC messages sent
+	MsgRcv   int64         `json:"msgrcv"`   // number of IPC messages received
+	NSignals int64         `json:"nsignals"` // number of signals received
+	NVCSw    int64         `json:"nvcsw"`    // number of voluntary context switches
+	NIVCSw   int64         `json:"nivcsw"`   // number of involuntary context switches
+}
+
+// String returns the key=value representation of the resources usage, as
+// written in the log footer.
+func (ru Rusage) String() string {
+	return fmt.Sprintf(
+		"utime=%v stime=%v maxrss=%d ixrss=%d idrss=%d isrss=%d "+
+			"minflt=%d majflt=%d nswap=%d inblock=%d oublock=%d "+
+			"msgsnd=%d msgrcv=%d nsignals=%d nvcsw=%d nivcsw=%d",
+		ru.UTime, ru.STime, ru.MaxRSS, ru.IxRSS, ru.IdRSS, ru.IsRSS,
+		ru.MinFlt, ru.MajFlt, ru.NSwap, ru.InBlock, ru.OuBlock,
+		ru.MsgSnd, ru.MsgRcv, ru.NSignals, ru.NVCSw, ru.NIVCSw,
+	)
+}
+
+// parseRusage parses the key=value representation of a resources usage.
+func parseRusage(txt string) (Rusage, error) {
+	var (
+		ru    Rusage
+		times = map[string]*time.Duration{
+			"utime": &ru.UTime,
+			"stime": &ru.STime,
+		}
+		counts = map[string]*int64{
+			"maxrss":   &ru.MaxRSS,
+			"ixrss":    &ru.IxRSS,
+			"idrss":    &ru.IdRSS,
+			"isrss":    &ru.IsRSS,
+			"minflt":   &ru.MinFlt,
+			"majflt":   &ru.MajFlt,
+			"nswap":    &ru.NSwap,
+			"inblock":  &ru.InBlock,
+			"oublock":  &ru.OuBlock,
+			"msgsnd":   &ru.MsgSnd,
+			"msgrcv":   &ru.MsgRcv,
+			"nsignals": &ru.NSignals,
+			"nvcsw":    &ru.NVCSw,
+			"nivcsw":   &ru.NIVCSw,
+		}
+	)
+	for _, field := range strings.Fields(txt) {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			return ru, fmt.Errorf("invalid rusage field %q", field)
+		}
+		var err error
+		switch {
+		case times[k] != nil:
+			*times[k], err = time.ParseDuration(v)
+		case counts[k] != nil:
+			*counts[k], err = strconv.ParseInt(v, 10, 64)
+		default:
+			// unknown field, from a newer pmon.
+		}
+		if err != nil {
+			return ru, fmt.Errorf("could not parse rusage field %q: %w", field, err)
+		}
+	}
+	return ru, nil
 }
 
 // Meta holds metadata about a pmon run.
@@ -36,13 +489,217 @@ type Meta struct {
 	Stop    time.Time
 
 	Infos []Infos
+
+	// Procs holds the time series of per-process infos, indexed by PID.
+	Procs map[int][]ProcInfos
+
+	// Threads holds the time series of per-thread infos, indexed by
+	// thread name.
+	// Threads sharing the same name (e.g. the workers of a pool) are
+	// stored in the same time series.
+	Threads map[string][]ThreadInfos
+
+	// Smaps holds the proportional and unique memory usage samples.
+	// Smaps are usually sampled less often than Infos.
+	Smaps []SmapsInfos
+
+	// Maps holds the memory usage of the largest memory mappings, for each
+	// sampling of /proc/<pid>/smaps.
+	Maps []MapsInfos
+
+	// FDs holds the file descriptors inventory samples.
+	FDs []FDInfos
+
+	// Sockets holds the number of sockets per protocol and state,
+	// for each FDs sample.
+	Sockets []SockInfos
+
+	// Files holds the positions in the opened regular files, for each
+	// sampling of /proc/<pid>/fdinfo.
+	Files []FileInfos
+
+	// Cgroups holds the cgroup v2 metrics samples.
+	Cgroups []CgroupInfos
+
+	// PSI holds the pressure stall information samples.
+	PSI []PSIInfos
+
+	// Host holds the system-wide resources usage samples.
+	Host []HostInfos
+
+	// States holds the number of threads per scheduler state samples.
+	States []StateInfos
+
+	// Wchans holds the number of threads per kernel wait channel, for
+	// each States sample.
+	Wchans []WchanInfos
+
+	// Syscalls holds the number of threads per blocking syscall, for each
+	// States sample.
+	Syscalls []SyscallInfos
+
+	// Strace holds the per-syscall statistics of the traced process(es),
+	// for each sampling period.
+	Strace []StraceInfos
+
+	// Events holds the lifecycle events (fork, exec, exit, signals) of
+	// the traced process(es), and the OOM kill of the monitored command.
+	Events []EventInfos
+
+	// Exit holds the exit status and resources usage of the monitored
+	// command, if it was started (and waited for) by pmon.
+	Exit *ExitInfos
+
+	// Target holds the metadata of the process monitored in attach mode.
+	Target *TargetInfos
+
+	// Env holds the metadata of the host and of the pmon build.
+	Env EnvInfos
+
+	// Labels holds the user-supplied key=value labels of the run.
+	Labels map[string]string
+
+	// Select holds the criteria of the selector of the monitored
+	// processes, in selection mode.
+	Select string
+
+	// Rescan reports whether the selector was re-resolved at each sample.
+	Rescan bool
+}
+
+// parseEnv parses a "# env-<key>: <value>" header line.
+func (meta *Meta) parseEnv(txt string) error {
+	key, v, ok := strings.Cut(txt[len("# env-"):], ": ")
+	if !ok {
+		return fmt.Errorf("invalid env header %q", txt)
+	}
+	var (
+		env = &meta.Env
+		err error
+	)
+	switch key {
+	case "hostname":
+		env.Hostname = v
+	case "kernel":
+		env.Kernel = v
+	case "cpu-model":
+		env.CPUModel = v
+	case "cpus":
+		env.CPUs, err = strconv.Atoi(v)
+	case "mem-total":
+		env.MemTotal, err = strconv.ParseInt(v, 10, 64)
+	case "page-size":
+		env.PageSize, err = strconv.ParseInt(v, 10, 64)
+	case "clock-ticks":
+		env.ClockTicks, err = strconv.ParseInt(v, 10, 64)
+	case "version":
+		env.Version = v
+	case "go-version":
+		env.GoVersion = v
+	default:
+		// unknown metadata, from a newer pmon.
+	}
+	if err != nil {
+		return fmt.Errorf("could not parse env %s %q: %w", key, txt, err)
+	}
+	return nil
+}
+
+// parseTarget parses a "# target-<key>: <value>" header line.
+func (meta *Meta) parseTarget(txt string) error {
+	key, v, ok := strings.Cut(txt[len("# target-"):], ": ")
+	if !ok {
+		return fmt.Errorf("invalid target header %q", txt)
+	}
+	if meta.Target == nil {
+		meta.Target = &TargetInfos{UID: -1, EUID: -1, GID: -1, EGID: -1}
+	}
+	var (
+		target = meta.Target
+		err    error
+	)
+	switch key {
+	case "pid":
+		target.PID, err = strconv.Atoi(v)
+	case "argv":
+		for v != "" {
+			var arg string
+			arg, err = strconv.QuotedPrefix(v)
+			if err != nil {
+				break
+			}
+			v = strings.TrimSpace(v[len(arg):])
+			arg, _ = strconv.Unquote(arg)
+			target.Argv = append(target.Argv, arg)
+		}
+	case "exe":
+		target.Exe = v
+	case "cwd":
+		target.Cwd = v
+	case "uid":
+		_, err = fmt.Sscanf(v, "%d %d", &target.UID, &target.EUID)
+	case "gid":
+		_, err = fmt.Sscanf(v, "%d %d", &target.GID, &target.EGID)
+	case "started":
+		target.Started, err = time.Parse(time.RFC3339Nano, v)
+	case "cgroup":
+		target.Cgroup = v
+	case "namespaces":
+		target.Namespaces = strings.Fields(v)
+	default:
+		// unknown metadata, from a newer pmon.
+	}
+	if err != nil {
+		return fmt.Errorf("could not parse target %s %q: %w", key, txt, err)
+	}
+	return nil
+}
+
+// exit returns the exit infos of the run, creating them if needed.
+func (meta *Meta) exit() *ExitInfos {
+	if meta.Exit == nil {
+		meta.Exit = new(ExitInfos)
+	}
+	return meta.Exit
+}
+
+// WchanHist returns the number of threads waiting in each kernel wait
+// channel, summed over all samples.
+func (meta Meta) WchanHist() map[string]int64 {
+	hist := make(map[string]int64)
+	for _, v := range meta.Wchans {
+		hist[v.Name] += v.N
+	}
+	return hist
+}
+
+// SyscallHist returns the number of threads blocked in each syscall,
+// summed over all samples.
+func (meta Meta) SyscallHist() map[string]int64 {
+	hist := make(map[string]int64)
+	for _, v := range meta.Syscalls {
+		hist[v.Name] += v.N
+	}
+	return hist
+}
+
+// ThreadCPU returns the user+system time of all the threads named name,
+// for each Infos sample.
+func (meta Meta) ThreadCPU(name string) []time.Duration {
+	cpu := make([]time.Duration, len(meta.Infos))
+	for _, v := range meta.Threads[name] {
+		if v.Tick < 0 || v.Tick >= len(cpu) {
+			continue
+		}
+		cpu[v.Tick] += v.UTime + v.STime
+	}
+	return cpu
 }
 
 // Parse parses a pmon run log file.
 func Parse(r io.Reader) (Meta, error) {
 	const (
 		layout = time.RFC3339Nano
-		ms2sec = 1 / 1000.0
 	)
 
 	var (
@@ -57,8 +714,8 @@ func Parse(r io.Reader) (Meta, error) {
 			continue
 		}
 
-		switch txt[0] {
-		case '#':
+		switch {
+		case txt[0] == '#':
 			switch {
 			case strings.HasPrefix(txt, "# pmon: "):
 				meta.Cmd = string(txt[len("# pmon: "):])
@@ -91,27 +748,76 @@ func Parse(r io.Reader) (Meta, error) {
 				}
 				meta.Stop = v
 
+			case strings.HasPrefix(txt, "# env-"):
+				err := meta.parseEnv(txt)
+				if err != nil {
+					return meta, err
+				}
+
+			case strings.HasPrefix(txt, "# label: "):
+				k, v, ok := strings.Cut(txt[len("# label: "):], "=")
+				if !ok {
+					return meta, fmt.Errorf("invalid label %q", txt)
+				}
+				if meta.Labels == nil {
+					meta.Labels = make(map[string]string)
+				}
+				meta.Labels[k] = v
+
+			case strings.HasPrefix(txt, "# select: "):
+				meta.Select = txt[len("# select: "):]
+
+			case strings.HasPrefix(txt, "# rescan: "):
+				v, err := strconv.ParseBool(txt[len("# rescan: "):])
+				if err != nil {
+					return meta, fmt.Errorf("could not parse rescan %q: %w", txt, err)
+				}
+				meta.Rescan = v
+
+			case strings.HasPrefix(txt, "# target-"):
+				err := meta.parseTarget(txt)
+				if err != nil {
+					return meta, err
+				}
+
+			case strings.HasPrefix(txt, "# exit-code: "):
+				v, err := strconv.Atoi(txt[len("# exit-code: "):])
+				if err != nil {
+					return meta, fmt.Errorf("could not parse exit code %q: %w", txt, err)
+				}
+				meta.exit().Code = v
+
+			case strings.HasPrefix(txt, "# signal: "):
+				meta.exit().Signal = txt[len("# signal: "):]
+
+			case strings.HasPrefix(txt, "# core-dump: "):
+				v, err := strconv.ParseBool(txt[len("# core-dump: "):])
+				if err != nil {
+					return meta, fmt.Errorf("could not parse core dump %q: %w", txt, err)
+				}
+				meta.exit().CoreDump = v
+
+			case strings.HasPrefix(txt, "# rusage: "):
+				v, err := parseRusage(txt[len("# rusage: "):])
+				if err != nil {
+					return meta, fmt.Errorf("could not parse rusage %q: %w", txt, err)
+				}
+				meta.exit().Rusage = v
 			}
+
+		case isRecord(txt):
+			e := meta.parseRecord(txt)
+			if e != nil {
+				err = errors.Join(err, e)
+				continue
+			}
+
 		default:
-			var (
-				v   Infos
-				cpu float64
-				usr float64
-				sys float64
-			)
-			_, e := fmt.Sscanf(txt, "%f %f %f %d %d %d %d %d %d %d",
-				&cpu, &usr, &sys, &v.VMem, &v.RSS,
-				&v.Threads,
-				&v.Rchar, &v.Wchar,
-				&v.Rdisk, &v.Wdisk,
-			)
+			v, e := parseInfos(txt)
 			if e != nil {
 				err = errors.Join(err, fmt.Errorf("could not scan pmon-info %q: %w", txt, e))
 				continue
 			}
-			v.CPU = time.Duration(int(cpu * ms2sec))
-			v.UTime = time.Duration(int(usr * ms2sec))
-			v.STime = time.Duration(int(sys * ms2sec))
 			meta.Infos = append(meta.Infos, v)
 		}
 	}
@@ -122,3 +828,228 @@ func Parse(r io.Reader) (Meta, error) {
 
 	return meta, err
 }
+
+// isRecord returns whether the provided line is a tagged record
+// (e.g. "proc: ...") rather than an Infos sample.
+func isRecord(txt string) bool {
+	c := txt[0]
+	return 'a' <= c && c <= 'z'
+}
+
+// parseRecord parses a tagged record.
+// Records are attached to the latest Infos sample.
+func (meta *Meta) parseRecord(txt string) error {
+	tag, rec, _ := strings.Cut(txt, ":")
+	rec = strings.TrimSpace(rec)
+	tick := len(meta.Infos) - 1
+
+	switch tag {
+	case "proc":
+		var (
+			v   = ProcInfos{Tick: tick}
+			cpu float64
+		)
+		_, err := fmt.Sscanf(rec, "%d %d %q %f %d %d %d %d %d",
+			&v.PID, &v.PPID, &v.Comm,
+			&cpu, &v.RSS,
+			&v.Rchar, &v.Wchar,
+			&v.Rdisk, &v.Wdisk,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-proc %q: %w", txt, err)
+		}
+		v.CPU = fromMilliseconds(cpu)
+		if meta.Procs == nil {
+			meta.Procs = make(map[int][]ProcInfos)
+		}
+		meta.Procs[v.PID] = append(meta.Procs[v.PID], v)
+
+	case "thread":
+		var (
+			v   = ThreadInfos{Tick: tick}
+			usr float64
+			sys float64
+		)
+		_, err := fmt.Sscanf(rec, "%d %q %c %f %f",
+			&v.TID, &v.Name, &v.State,
+			&usr, &sys,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-thread %q: %w", txt, err)
+		}
+		v.UTime = fromMilliseconds(usr)
+		v.STime = fromMilliseconds(sys)
+		if meta.Threads == nil {
+			meta.Threads = make(map[string][]ThreadInfos)
+		}
+		meta.Threads[v.Name] = append(meta.Threads[v.Name], v)
+
+	case "smaps":
+		v := SmapsInfos{Tick: tick}
+		_, err := fmt.Sscanf(rec, "%d %d %d %d",
+			&v.PSS, &v.USS, &v.Swap, &v.SwapPSS,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-smaps %q: %w", txt, err)
+		}
+		meta.Smaps = append(meta.Smaps, v)
+
+	case "map":
+		v := MapsInfos{Tick: tick}
+		_, err := fmt.Sscanf(rec, "%q %d %d %d %d",
+			&v.Path, &v.RSS, &v.PSS, &v.Private, &v.AnonHuge,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-map %q: %w", txt, err)
+		}
+		meta.Maps = append(meta.Maps, v)
+
+	case "fds":
+		v := FDInfos{Tick: tick}
+		_, err := fmt.Sscanf(rec, "%d %d %d %d %d %d %d %d %d",
+			&v.Total, &v.Files, &v.Pipes, &v.Sockets,
+			&v.EventFDs, &v.AnonInodes, &v.Others,
+			&v.Peak, &v.Limit,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-fds %q: %w", txt, err)
+		}
+		meta.FDs = append(meta.FDs, v)
+
+	case "sock":
+		v := SockInfos{Tick: tick}
+		_, err := fmt.Sscanf(rec, "%s %s %d", &v.Proto, &v.State, &v.N)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-sock %q: %w", txt, err)
+		}
+		meta.Sockets = append(meta.Sockets, v)
+
+	case "file":
+		v := FileInfos{Tick: tick}
+		_, err := fmt.Sscanf(rec, "%d %d %d %d %s %q",
+			&v.PID, &v.FD, &v.Pos, &v.Size, &v.Mode, &v.Path,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-file %q: %w", txt, err)
+		}
+		meta.Files = append(meta.Files, v)
+
+	case "cgroup":
+		var (
+			v         = CgroupInfos{Tick: tick}
+			cpu       float64
+			throttled float64
+		)
+		_, err := fmt.Sscanf(rec, "%d %d %d %d %d %d %d %d %f %d %f %d %d %d %d %q",
+			&v.Memory, &v.Anon, &v.File, &v.Kernel, &v.Sock,
+			&v.High, &v.OOM, &v.OOMKill,
+			&cpu, &v.NrThrottled, &throttled,
+			&v.Rdisk, &v.Wdisk, &v.Rios, &v.Wios,
+			&v.Path,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-cgroup %q: %w", txt, err)
+		}
+		v.CPU = fromMilliseconds(cpu)
+		v.Throttled = fromMilliseconds(throttled)
+		meta.Cgroups = append(meta.Cgroups, v)
+
+	case "psi":
+		var (
+			v    = PSIInfos{Tick: tick}
+			some float64
+			full float64
+		)
+		_, err := fmt.Sscanf(rec, "%s %s %f %f %f %f",
+			&v.Source, &v.Resource,
+			&v.SomeAvg10, &some,
+			&v.FullAvg10, &full,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-psi %q: %w", txt, err)
+		}
+		v.SomeTotal = fromMilliseconds(some)
+		v.FullTotal = fromMilliseconds(full)
+		meta.PSI = append(meta.PSI, v)
+
+	case "host":
+		var (
+			v                          = HostInfos{Tick: tick}
+			busy, iowait, steal, total float64
+		)
+		_, err := fmt.Sscanf(rec, "%f %f %f %d %d %d %d %d %f %f %f %f %d %d %d %d",
+			&v.Load1, &v.Load5, &v.Load15,
+			&v.MemAvailable, &v.Cached, &v.Dirty, &v.Writeback, &v.SwapFree,
+			&busy, &iowait, &steal, &total,
+			&v.Rdisk, &v.Wdisk,
+			&v.NetRx, &v.NetTx,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-host %q: %w", txt, err)
+		}
+		v.Busy = fromMilliseconds(busy)
+		v.IOWait = fromMilliseconds(iowait)
+		v.Steal = fromMilliseconds(steal)
+		v.Total = fromMilliseconds(total)
+		meta.Host = append(meta.Host, v)
+
+	case "states":
+		v := StateInfos{Tick: tick}
+		_, err := fmt.Sscanf(rec, "%d %d %d %d",
+			&v.Running, &v.Sleeping, &v.Disk, &v.Other,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-states %q: %w", txt, err)
+		}
+		meta.States = append(meta.States, v)
+
+	case "wchan":
+		v := WchanInfos{Tick: tick}
+		_, err := fmt.Sscanf(rec, "%q %d", &v.Name, &v.N)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-wchan %q: %w", txt, err)
+		}
+		meta.Wchans = append(meta.Wchans, v)
+
+	case "syscall":
+		v := SyscallInfos{Tick: tick}
+		_, err := fmt.Sscanf(rec, "%d %q %d", &v.Nr, &v.Name, &v.N)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-syscall %q: %w", txt, err)
+		}
+		meta.Syscalls = append(meta.Syscalls, v)
+
+	case "strace":
+		var (
+			v  = StraceInfos{Tick: tick}
+			ms float64
+		)
+		_, err := fmt.Sscanf(rec, "%d %q %d %d %f",
+			&v.Nr, &v.Name, &v.Calls, &v.Errors, &ms,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-strace %q: %w", txt, err)
+		}
+		v.Time = fromMilliseconds(ms)
+		meta.Strace = append(meta.Strace, v)
+
+	case "event":
+		var (
+			v  = EventInfos{Tick: tick}
+			ms float64
+		)
+		_, err := fmt.Sscanf(rec, "%f %s %d %d %q",
+			&ms, &v.Kind, &v.PID, &v.Value, &v.Detail,
+		)
+		if err != nil {
+			return fmt.Errorf("could not scan pmon-event %q: %w", txt, err)
+		}
+		v.Time = fromMilliseconds(ms)
+		meta.Events = append(meta.Events, v)
+
+	default:
+		return fmt.Errorf("unknown pmon record %q", txt)
+	}
+
+	return nil
+}
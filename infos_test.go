@@ -0,0 +1,355 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfosRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		v    Infos
+	}{
+		{
+			name: "zero",
+		},
+		{
+			name: "full",
+			v: Infos{
+				CPU: 1500 * time.Millisecond, UTime: 1200 * time.Millisecond, STime: 300 * time.Millisecond,
+				VMem: 2048, RSS: 1024,
+				Threads: 4,
+				Rchar:   1, Wchar: 2,
+				Rdisk: 3, Wdisk: 4,
+				RSSAnon: 512, RSSFile: 500, RSSShmem: 12,
+				Swap:    8,
+				RSSPeak: 1100, VMemPeak: 2100,
+				MinFlt: 10, MajFlt: 11, CMinFlt: 12, CMajFlt: 13,
+				CUTime: 40 * time.Millisecond, CSTime: 20 * time.Millisecond,
+				Priority: 20, Nice: -5,
+				State:      'S',
+				StartTime:  532323 * time.Millisecond,
+				VolCtxSw:   100,
+				InvolCtxSw: 7,
+				RunTime:    1250 * time.Millisecond, WaitTime: 25 * time.Millisecond,
+				Timeslices: 42,
+				Syscr:      5, Syscw: 6, Cwdisk: 7,
+			},
+		},
+		{
+			name: "no schedstat",
+			v:    Infos{State: 'R', Timeslices: -1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			txt := fmt.Sprintf(infosFmt, tc.v.args()...)
+			got, err := parseInfos(txt)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", txt, err)
+			}
+			if got != tc.v {
+				t.Fatalf("invalid round-trip of %q:\ngot= %+v\nwant=%+v", txt, got, tc.v)
+			}
+		})
+	}
+}
+
+func TestParseInfosColumns(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		txt  string
+		want Infos
+	}{
+		{
+			name: "original format",
+			txt:  "1000.000000 800.000000 200.000000 2048 1024 3 1 2 3 4",
+			want: Infos{
+				CPU: time.Second, UTime: 800 * time.Millisecond, STime: 200 * time.Millisecond,
+				VMem: 2048, RSS: 1024, Threads: 3,
+				Rchar: 1, Wchar: 2, Rdisk: 3, Wdisk: 4,
+			},
+		},
+		{
+			name: "memory breakdown",
+			txt:  "1000.000000 800.000000 200.000000 2048 1024 3 1 2 3 4 512 500 12 8 1100 2100",
+			want: Infos{
+				CPU: time.Second, UTime: 800 * time.Millisecond, STime: 200 * time.Millisecond,
+				VMem: 2048, RSS: 1024, Threads: 3,
+				Rchar: 1, Wchar: 2, Rdisk: 3, Wdisk: 4,
+				RSSAnon: 512, RSSFile: 500, RSSShmem: 12, Swap: 8,
+				RSSPeak: 1100, VMemPeak: 2100,
+			},
+		},
+		{
+			name: "unknown state",
+			txt:  "0.000000 0.000000 0.000000 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0.000000 0.000000 0 0 - 0.000000",
+			want: Infos{},
+		},
+		{
+			name: "newer format",
+			txt:  "0.000000 0.000000 0.000000 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0.000000 0.000000 0 0 R 0.000000 0 0 0.000000 0.000000 0 0 0 0 42",
+			want: Infos{State: 'R'},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseInfos(tc.txt)
+			if err != nil {
+				t.Fatalf("could not parse infos: %+v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("invalid infos:\ngot= %+v\nwant=%+v", got, tc.want)
+			}
+		})
+	}
+
+	for _, txt := range []string{
+		"1000.000000 800.000000 200.000000 2048 1024", // too few columns
+		"1000.000000 800.000000 200.000000 2048 x 3 1 2 3 4",
+	} {
+		_, err := parseInfos(txt)
+		if err == nil {
+			t.Fatalf("expected an error parsing %q", txt)
+		}
+	}
+}
+
+func TestRusageRoundTrip(t *testing.T) {
+	want := Rusage{
+		UTime: 1234 * time.Millisecond, STime: 56 * time.Millisecond,
+		MaxRSS: 1024,
+		IxRSS:  1, IdRSS: 2, IsRSS: 3,
+		MinFlt: 4, MajFlt: 5,
+		NSwap:   6,
+		InBlock: 7, OuBlock: 8,
+		MsgSnd: 9, MsgRcv: 10,
+		NSignals: 11,
+		NVCSw:    12, NIVCSw: 13,
+	}
+	got, err := parseRusage(want.String())
+	if err != nil {
+		t.Fatalf("could not parse rusage: %+v", err)
+	}
+	if got != want {
+		t.Fatalf("invalid round-trip:\ngot= %+v\nwant=%+v", got, want)
+	}
+
+	// unknown fields, from a newer pmon, are ignored.
+	got, err = parseRusage("maxrss=42 newfield=3")
+	if err != nil {
+		t.Fatalf("could not parse rusage with unknown fields: %+v", err)
+	}
+	if got != (Rusage{MaxRSS: 42}) {
+		t.Fatalf("invalid rusage: %+v", got)
+	}
+
+	for _, txt := range []string{"maxrss", "maxrss=x", "utime=3"} {
+		_, err := parseRusage(txt)
+		if err == nil {
+			t.Fatalf("expected an error parsing %q", txt)
+		}
+	}
+}
+
+func TestExitInfos(t *testing.T) {
+	for _, tc := range []struct {
+		exit   ExitInfos
+		status int
+		str    string
+	}{
+		{ExitInfos{Code: 0}, 0, "exit status 0"},
+		{ExitInfos{Code: 3}, 3, "exit status 3"},
+		{ExitInfos{Code: -1, Signal: "SIGKILL"}, 137, "signal: SIGKILL"},
+		{ExitInfos{Code: -1, Signal: "SIGSEGV", CoreDump: true}, 139, "signal: SIGSEGV (core dumped)"},
+	} {
+		if got, want := tc.exit.Status(), tc.status; got != want {
+			t.Errorf("invalid status of %+v: got=%d, want=%d", tc.exit, got, want)
+		}
+		if got, want := tc.exit.String(), tc.str; got != want {
+			t.Errorf("invalid string of %+v: got=%q, want=%q", tc.exit, got, want)
+		}
+	}
+}
+
+// TestParse checks that the headers, samples, records and footers written by
+// a Process are parsed back.
+func TestParse(t *testing.T) {
+	var (
+		buf = new(bytes.Buffer)
+		p   = &Process{
+			W:      buf,
+			Labels: map[string]string{"run": "42", "host": "node-1"},
+		}
+
+		start = time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+		stop  = start.Add(2 * time.Second)
+
+		target = TargetInfos{
+			PID:        1234,
+			Argv:       []string{"/bin/sh", "-c", "sleep 1; echo \"done\""},
+			Exe:        "/usr/bin/dash",
+			Cwd:        "/tmp",
+			UID:        1000,
+			EUID:       0,
+			GID:        100,
+			EGID:       100,
+			Started:    start.Add(-time.Hour),
+			Cgroup:     "/user.slice/run.scope",
+			Namespaces: []string{"mnt:[4026531841]", "pid:[4026531836]"},
+		}
+		exit = ExitInfos{
+			Code:     -1,
+			Signal:   "SIGKILL",
+			CoreDump: false,
+			Rusage:   Rusage{UTime: 1500 * time.Millisecond, MaxRSS: 2048, NVCSw: 12},
+		}
+		infos = []Infos{
+			{CPU: 10 * time.Millisecond, UTime: 10 * time.Millisecond, VMem: 2048, RSS: 1024, Threads: 1, Rchar: 1, Wchar: 2, Rdisk: 3, Wdisk: 4, State: 'R', Timeslices: -1},
+			{CPU: 20 * time.Millisecond, UTime: 15 * time.Millisecond, STime: 5 * time.Millisecond, VMem: 4096, RSS: 2048, Threads: 2, State: 'S', RSSPeak: 2048},
+		}
+	)
+
+	fmt.Fprintf(buf,
+		"# pmon: sh -c sleep\n# freq: %v\n# format: %#v\n# start: %v\n",
+		500*time.Millisecond, Infos{}, start.Format(time.RFC3339Nano),
+	)
+	p.writeEnv()
+	fmt.Fprintf(buf, "# select: comm=%q\n# rescan: true\n", "sh")
+	p.writeTarget(target)
+
+	fmt.Fprintf(buf, infosFmt+"\n", infos[0].args()...)
+	fmt.Fprintf(buf, "proc: 1234 1 %q 10.000000 1024 1 2 3 4\n", "sh")
+	fmt.Fprintf(buf, "thread: 1234 %q R 10.000000 0.000000\n", "sh")
+	fmt.Fprintf(buf, "fds: 5 1 2 1 0 1 0 5 1024\n")
+	fmt.Fprintf(buf, "sock: tcp ESTABLISHED 1\n")
+	fmt.Fprintf(buf, "event: 1.500000 fork 1235 1234 \"\"\n")
+	fmt.Fprintf(buf, "event: 2.000000 exec 1235 0 %q\n", "sleep 1")
+
+	fmt.Fprintf(buf, infosFmt+"\n", infos[1].args()...)
+	fmt.Fprintf(buf, "proc: 1235 1234 %q 5.000000 512 0 0 0 0\n", "sleep")
+	fmt.Fprintf(buf, "fds: 3 0 0 0 0 0 3 3 -1\n")
+	fmt.Fprintf(buf, "psi: host memory 1.250000 12.000000 0.500000 6.000000\n")
+	fmt.Fprintf(buf, "strace: 0 %q 10 1 0.250000\n", "read")
+	fmt.Fprintf(buf, "event: 1999.000000 oom-killed 1234 2048 %q\n", "(probable) system")
+
+	fmt.Fprintf(buf,
+		"# elapsed: %v\n# stop: %v\n",
+		stop.Sub(start), stop.Format(time.RFC3339Nano),
+	)
+	p.exit = &exit
+	p.writeExit()
+
+	meta, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("could not parse log: %+v", err)
+	}
+
+	if got, want := meta.Cmd, "sh -c sleep"; got != want {
+		t.Errorf("invalid command: got=%q, want=%q", got, want)
+	}
+	if got, want := meta.Freq, 500*time.Millisecond; got != want {
+		t.Errorf("invalid frequency: got=%v, want=%v", got, want)
+	}
+	if !meta.Start.Equal(start) || !meta.Stop.Equal(stop) || meta.Elapsed != stop.Sub(start) {
+		t.Errorf("invalid times: start=%v stop=%v elapsed=%v", meta.Start, meta.Stop, meta.Elapsed)
+	}
+	if got, want := meta.Env, readEnv(); got != want {
+		t.Errorf("invalid env:\ngot= %+v\nwant=%+v", got, want)
+	}
+	if got, want := meta.Labels, p.Labels; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid labels: got=%v, want=%v", got, want)
+	}
+	if got, want := meta.Select, `comm="sh"`; got != want || !meta.Rescan {
+		t.Errorf("invalid selector: got=%q (rescan=%v), want=%q (rescan=true)", got, meta.Rescan, want)
+	}
+	if meta.Target == nil {
+		t.Fatalf("missing target")
+	}
+	if got, want := *meta.Target, target; !got.Started.Equal(want.Started) {
+		t.Errorf("invalid target start: got=%v, want=%v", got.Started, want.Started)
+	} else {
+		got.Started = want.Started
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("invalid target:\ngot= %+v\nwant=%+v", got, want)
+		}
+	}
+	if meta.Exit == nil || *meta.Exit != exit {
+		t.Errorf("invalid exit: got=%+v, want=%+v", meta.Exit, exit)
+	}
+
+	if got, want := meta.Infos, infos; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid infos:\ngot= %+v\nwant=%+v", got, want)
+	}
+	if got, want := meta.Procs, map[int][]ProcInfos{
+		1234: {{Tick: 0, PID: 1234, PPID: 1, Comm: "sh", CPU: 10 * time.Millisecond, RSS: 1024, Rchar: 1, Wchar: 2, Rdisk: 3, Wdisk: 4}},
+		1235: {{Tick: 1, PID: 1235, PPID: 1234, Comm: "sleep", CPU: 5 * time.Millisecond, RSS: 512}},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid procs:\ngot= %+v\nwant=%+v", got, want)
+	}
+	if got, want := meta.Threads, map[string][]ThreadInfos{
+		"sh": {{Tick: 0, TID: 1234, Name: "sh", State: 'R', UTime: 10 * time.Millisecond}},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid threads:\ngot= %+v\nwant=%+v", got, want)
+	}
+	if got, want := meta.FDs, []FDInfos{
+		{Tick: 0, Total: 5, Files: 1, Pipes: 2, Sockets: 1, AnonInodes: 1, Peak: 5, Limit: 1024},
+		{Tick: 1, Total: 3, Others: 3, Peak: 3, Limit: -1},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid fds:\ngot= %+v\nwant=%+v", got, want)
+	}
+	if got, want := meta.Sockets, []SockInfos{
+		{Tick: 0, Proto: "tcp", State: "ESTABLISHED", N: 1},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid sockets:\ngot= %+v\nwant=%+v", got, want)
+	}
+	if got, want := meta.PSI, []PSIInfos{
+		{Tick: 1, Source: "host", Resource: "memory", SomeAvg10: 1.25, SomeTotal: 12 * time.Millisecond, FullAvg10: 0.5, FullTotal: 6 * time.Millisecond},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid psi:\ngot= %+v\nwant=%+v", got, want)
+	}
+	if got, want := meta.Strace, []StraceInfos{
+		{Tick: 1, Nr: 0, Name: "read", Calls: 10, Errors: 1, Time: 250 * time.Microsecond},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid strace:\ngot= %+v\nwant=%+v", got, want)
+	}
+	if got, want := meta.Events, []EventInfos{
+		{Tick: 0, Time: 1500 * time.Microsecond, Kind: "fork", PID: 1235, Value: 1234},
+		{Tick: 0, Time: 2 * time.Millisecond, Kind: "exec", PID: 1235, Detail: "sleep 1"},
+		{Tick: 1, Time: 1999 * time.Millisecond, Kind: "oom-killed", PID: 1234, Value: 2048, Detail: "(probable) system"},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid events:\ngot= %+v\nwant=%+v", got, want)
+	}
+}
+
+func TestParsePartial(t *testing.T) {
+	const log = `# pmon: true
+# freq: 1s
+10.000000 10.000000 0.000000 2048 1024 1 0 0 0 0
+10.000000 x
+proc: 1 2
+unknown: 1 2 3
+20.000000 20.000000 0.000000 2048 1024 1 0 0 0 0
+`
+	meta, err := Parse(strings.NewReader(log))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	for _, want := range []string{"pmon-info", "pmon-proc", "unknown pmon record"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in error: %+v", want, err)
+		}
+	}
+	if got, want := len(meta.Infos), 2; got != want {
+		t.Fatalf("invalid number of samples: got=%d, want=%d", got, want)
+	}
+	if got, want := meta.Infos[1].CPU, 20*time.Millisecond; got != want {
+		t.Fatalf("invalid last sample: got=%v, want=%v", got, want)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// mksysnames generates the table of syscall names of a Linux architecture
+// from the kernel unistd header.
+// Architectures using the generic syscall table, such as arm64, are
+// generated from the asm-generic header.
+//
+// Usage:
+//
+//	$ go run ./internal/mksysnames -arch amd64 /usr/include/x86_64-linux-gnu/asm/unistd_64.h
+//	$ go run ./internal/mksysnames -arch arm64 /usr/include/asm-generic/unistd.h
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	log.SetPrefix("mksysnames: ")
+	log.SetFlags(0)
+
+	arch := flag.String("arch", "amd64", "GOARCH of the syscall table")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		log.Fatalf("missing path to unistd header")
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("could not open unistd header: %+v", err)
+	}
+	defer f.Close()
+
+	var (
+		re    = regexp.MustCompile(`^#define __NR(3264)?_(\w+)\s+(\d+)`)
+		alias = regexp.MustCompile(`^#define __NR_(\w+)\s+__NR3264_(\w+)`)
+		names = make(map[int]string)
+		nrs   = make(map[string]int) // numbers of the generic 32/64-bit syscalls
+		nmax  = 0
+		sc    = bufio.NewScanner(f)
+
+		// conds holds whether each enclosing conditional block is part of
+		// the table of a 64-bit architecture.
+		// Conditions on the architecture features are assumed to hold.
+		conds []cond
+	)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "#if"):
+			conds = append(conds, cond{
+				ok:   !strings.Contains(line, "__BITS_PER_LONG == 32"),
+				size: strings.Contains(line, "__BITS_PER_LONG"),
+			})
+			continue
+		case strings.HasPrefix(line, "#else") && len(conds) > 0:
+			if c := &conds[len(conds)-1]; c.size {
+				c.ok = !c.ok
+			}
+			continue
+		case strings.HasPrefix(line, "#endif") && len(conds) > 0:
+			conds = conds[:len(conds)-1]
+			continue
+		}
+		if slices.ContainsFunc(conds, func(c cond) bool { return !c.ok }) {
+			continue
+		}
+
+		if m := alias.FindStringSubmatch(line); m != nil {
+			nr, ok := nrs[m[2]]
+			if !ok {
+				// generic syscall not defined by this architecture.
+				continue
+			}
+			names[nr] = m[1]
+			nmax = max(nmax, nr)
+			continue
+		}
+
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		switch m[2] {
+		case "syscalls", "arch_specific_syscall":
+			// bounds of the generic syscall table, not syscalls.
+			continue
+		}
+		nr, err := strconv.Atoi(m[3])
+		if err != nil {
+			log.Fatalf("could not parse syscall number %q: %+v", m[3], err)
+		}
+		if m[1] != "" {
+			// named by the word size specific aliases.
+			nrs[m[2]] = nr
+			continue
+		}
+		names[nr] = m[2]
+		nmax = max(nmax, nr)
+	}
+	if err := sc.Err(); err != nil {
+		log.Fatalf("could not scan unistd header: %+v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Code generated by \"go run ./internal/mksysnames -arch %s %s\"; DO NOT EDIT.\n\n", *arch, flag.Arg(0))
+	fmt.Fprintf(buf, "package pmon\n\n")
+	fmt.Fprintf(buf, "var sysnames = [...]string{\n")
+	for nr := 0; nr <= nmax; nr++ {
+		name, ok := names[nr]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(buf, "\t%d: %q,\n", nr, name)
+	}
+	fmt.Fprintf(buf, "}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("could not format generated code: %+v", err)
+	}
+
+	err = os.WriteFile("zsysnames_linux_"+*arch+".go", src, 0644)
+	if err != nil {
+		log.Fatalf("could not write generated code: %+v", err)
+	}
+}
+
+// cond is a conditional block of a unistd header.
+type cond struct {
+	ok   bool // whether the block is part of the table
+	size bool // whether the block is conditioned on the word size
+}
@@ -7,7 +7,9 @@ package pmon
 import (
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -19,16 +21,53 @@ func Monitor(pid int) (*Process, error) {
 	}
 
 	proc := &Process{
-		Freq:  1 * time.Second,
-		W:     io.Discard,
-		quit:  make(chan struct{}),
-		start: func() error { return nil },
+		Msg:    log.Default(),
+		Freq:   1 * time.Second,
+		W:      io.Discard,
+		quit:   make(chan struct{}),
+		detach: make(chan struct{}),
+		start:  func() error { return nil },
 
 		proc: p,
 	}
 
+	// in attach mode, killing the monitoring detaches from the process,
+	// which is left running.
+	var once sync.Once
 	proc.stop = func() error {
-		close(proc.quit)
+		once.Do(func() { close(proc.detach) })
+		return nil
+	}
+
+	return proc, nil
+}
+
+// Select monitors the resources usage of the processes matching the
+// provided selector, summed over all of them.
+// The selector is resolved when the monitoring starts, and at each sample
+// if Rescan is set.
+// Tree adds the descendants of the selected processes to the monitoring.
+func Select(sel Selector) (*Process, error) {
+	if sel.empty() {
+		return nil, fmt.Errorf("empty process selector")
+	}
+
+	proc := &Process{
+		Msg:    log.Default(),
+		Freq:   1 * time.Second,
+		W:      io.Discard,
+		quit:   make(chan struct{}),
+		detach: make(chan struct{}),
+		start:  func() error { return nil },
+
+		sel: &sel,
+	}
+
+	// as in attach mode, killing the monitoring leaves the processes
+	// running.
+	var once sync.Once
+	proc.stop = func() error {
+		once.Do(func() { close(proc.detach) })
 		return nil
 	}
 
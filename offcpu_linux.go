@@ -0,0 +1,88 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"bytes"
+	"cmp"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// collectOffCPU collects the scheduler state, the kernel wait channel and
+// the syscall of each thread of the monitored processes, from
+// /proc/<pid>/task/<tid>/{stat,wchan,syscall}.
+func (c *collector) collectOffCPU() (*StateInfos, []WchanInfos, []SyscallInfos) {
+	var (
+		states   StateInfos
+		wchans   = make(map[string]int64)
+		syscalls = make(map[int]int64)
+	)
+	for _, pid := range c.pids() {
+		dir := "/proc/" + strconv.Itoa(pid) + "/task/"
+		tasks, err := os.ReadDir(dir)
+		if err != nil {
+			c.msg.Printf("could not read tasks of pid=%d: %+v", pid, err)
+			continue
+		}
+		for _, task := range tasks {
+			tdir := dir + task.Name() + "/"
+			raw, err := os.ReadFile(tdir + "stat")
+			if err != nil {
+				// thread may have exited in the meantime.
+				continue
+			}
+			stat, err := parseStat(raw)
+			if err != nil {
+				continue
+			}
+			switch stat.state {
+			case 'R':
+				states.Running++
+				continue
+			case 'S':
+				states.Sleeping++
+			case 'D':
+				states.Disk++
+			default:
+				states.Other++
+			}
+
+			if raw, err := os.ReadFile(tdir + "wchan"); err == nil {
+				name := string(bytes.TrimSpace(raw))
+				if name != "" && name != "0" {
+					wchans[name]++
+				}
+			}
+
+			if raw, err := os.ReadFile(tdir + "syscall"); err == nil {
+				// "running", "-1 sp pc" or "nr args... sp pc"
+				fields := bytes.Fields(raw)
+				if len(fields) == 0 {
+					continue
+				}
+				nr, err := strconv.Atoi(string(fields[0]))
+				if err == nil && nr >= 0 {
+					syscalls[nr]++
+				}
+			}
+		}
+	}
+
+	ws := make([]WchanInfos, 0, len(wchans))
+	for name, n := range wchans {
+		ws = append(ws, WchanInfos{Name: name, N: n})
+	}
+	slices.SortFunc(ws, func(a, b WchanInfos) int { return cmp.Compare(a.Name, b.Name) })
+
+	ss := make([]SyscallInfos, 0, len(syscalls))
+	for nr, n := range syscalls {
+		ss = append(ss, SyscallInfos{Nr: nr, Name: syscallName(nr), N: n})
+	}
+	slices.SortFunc(ss, func(a, b SyscallInfos) int { return cmp.Compare(a.Nr, b.Nr) })
+
+	return &states, ws, ss
+}
@@ -0,0 +1,14 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+// oomWatch is not implemented on darwin.
+type oomWatch struct{}
+
+func newOOMWatch(pid int) *oomWatch { return &oomWatch{} }
+
+func (w *oomWatch) check(rss int64) (killed, certain bool, limit int64, source string) {
+	return false, false, 0, ""
+}
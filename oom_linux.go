@@ -0,0 +1,117 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// oomWatch holds the OOM kill counters observed at the start of the
+// monitoring, to attribute a SIGKILL of the monitored process to the kernel
+// OOM killer.
+type oomWatch struct {
+	cgroups []string // cgroup v2 directories of the process and of its ancestors
+	own     bool     // whether the cgroup of the process only holds it (and pmon)
+	kills   int64    // oom_kill counter of the cgroup of the process
+	global  int64    // oom_kill counter of the system
+}
+
+func newOOMWatch(pid int) *oomWatch {
+	var w oomWatch
+	if root, err := cgroupRoot(); err == nil {
+		if path, err := cgroupPath(pid); err == nil {
+			for dir := filepath.Join(root, path); ; dir = filepath.Dir(dir) {
+				w.cgroups = append(w.cgroups, dir)
+				if dir == root || dir == "/" {
+					break
+				}
+			}
+			w.own = path != "/" && ownCgroup(w.cgroups[0], pid)
+		}
+	}
+	w.kills, w.global = w.counters()
+	return &w
+}
+
+// counters returns the current oom_kill counters of the cgroup of the
+// process, from memory.events, and of the system, from /proc/vmstat.
+func (w *oomWatch) counters() (kills, global int64) {
+	if len(w.cgroups) > 0 {
+		_ = readCgroupKV(w.cgroups[0], "memory.events", map[string]*int64{
+			"oom_kill": &kills,
+		})
+	}
+	_ = readCgroupKV("/proc", "vmstat", map[string]*int64{
+		"oom_kill": &global,
+	})
+	return kills, global
+}
+
+// ownCgroup returns whether the provided cgroup only holds the provided
+// process and the current one.
+func ownCgroup(dir string, pid int) bool {
+	raw, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return false
+	}
+	self := os.Getpid()
+	for _, v := range strings.Fields(string(raw)) {
+		id, err := strconv.Atoi(v)
+		if err != nil || (id != pid && id != self) {
+			return false
+		}
+	}
+	return true
+}
+
+// check returns whether the OOM killer killed a process since the start of
+// the monitoring, whether that process was certainly the monitored one,
+// with its last sampled RSS (kB), and the memory limit that was hit (kB).
+// The limit is the lowest memory.max of the cgroup of the process and of its
+// ancestors, or the system memory for a system-wide OOM kill.
+//
+// An OOM kill in the cgroup of the process is attributed to it if the cgroup
+// is its own. A system-wide OOM kill is attributed to it if its RSS was
+// close to the memory available once it was killed, i.e. if it held most of
+// the memory the OOM killer freed.
+func (w *oomWatch) check(rss int64) (killed, certain bool, limit int64, source string) {
+	kills, global := w.counters()
+	switch {
+	case kills > w.kills:
+		limit = math.MaxInt64
+		for _, dir := range w.cgroups {
+			v, err := readCgroupValue(dir, "memory.max")
+			if err != nil || v/1024 >= limit {
+				// no controller, or "max".
+				continue
+			}
+			limit = v / 1024 // in kB
+			source = fmt.Sprintf("cgroup %s memory.max", dir)
+		}
+		if source == "" {
+			limit = -1
+			source = "cgroup " + w.cgroups[0]
+		}
+		return true, w.own, limit, source
+
+	case global > w.global:
+		limit = -1
+		avail := int64(-1)
+		if raw, err := os.ReadFile("/proc/meminfo"); err == nil {
+			_ = scanKV(raw, map[string]*int64{
+				"MemTotal":     &limit,
+				"MemAvailable": &avail,
+			})
+		}
+		certain = rss > 0 && avail > 0 && rss >= avail/2
+		return true, certain, limit, "system MemTotal"
+	}
+	return false, false, 0, ""
+}
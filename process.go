@@ -5,23 +5,148 @@
 package pmon
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	sys "golang.org/x/sys/unix"
 )
 
-// Process holds informations about a process created by New or Monitor.
+// Process holds informations about a process created by New, Monitor or
+// Select.
 type Process struct {
 	W    io.Writer
 	Freq time.Duration
 
-	quit chan struct{}
+	// Tree enables the monitoring of the whole process tree:
+	// resources usage is summed over the monitored process and all its
+	// descendants, including the CPU time of the exited ones.
+	Tree bool
+
+	// Breakdown enables the recording of per-process resources usage
+	// for each process of the monitored tree, alongside the summed one.
+	// Breakdown implies Tree.
+	Breakdown bool
+
+	// Threads enables the recording of the state and CPU usage of each
+	// thread of the monitored process(es).
+	Threads bool
+
+	// SmapsFreq is the sampling period of the proportional (PSS) and
+	// unique (USS) memory usage, read from /proc/<pid>/smaps_rollup.
+	// Reading smaps_rollup is expensive, so it is usually sampled less
+	// often than Freq.
+	// A zero value disables this sampling.
+	SmapsFreq time.Duration
+
+	// MapsFreq is the sampling period of the memory usage per mapping
+	// (heap, stack, anonymous mappings, shared libraries, mapped files...),
+	// read from /proc/<pid>/smaps.
+	// A zero value disables this sampling.
+	MapsFreq time.Duration
+
+	// MapsTop is the number of largest mappings recorded at each sampling
+	// of /proc/<pid>/smaps.
+	// A non-positive value records the 10 largest mappings.
+	MapsTop int
+
+	// FDs enables the recording of the inventory of the file descriptors
+	// opened by the monitored process(es), and of their connections per
+	// protocol and state.
+	FDs bool
+
+	// ProgressFreq is the sampling period of the positions of the monitored
+	// process(es) in the regular files they have opened, read from
+	// /proc/<pid>/fdinfo.
+	// At each sampling, the throughput and estimated completion time of the
+	// largest file opened for reading are reported on Msg.
+	// A zero value disables this sampling.
+	ProgressFreq time.Duration
+
+	// Cgroup enables the recording of the metrics (memory, CPU, I/O) of the
+	// cgroup v2 of the monitored process.
+	Cgroup bool
+
+	// PSI enables the recording of the pressure stall information (CPU,
+	// memory and I/O) of the whole system and of the cgroup v2 of the
+	// monitored process.
+	PSI bool
+
+	// Host enables the recording of system-wide resources usage (load
+	// average, memory, CPU, disk and network), alongside each sample of
+	// the monitored process.
+	Host bool
+
+	// OffCPU enables the recording, at each tick, of the scheduler state
+	// (running, sleeping, uninterruptible), of the kernel wait channel and
+	// of the syscall of each thread of the monitored process(es).
+	// A summary of the wait channels and syscalls the threads were blocked
+	// in is reported on Msg at the end of the monitoring.
+	OffCPU bool
+
+	// Strace enables the tracing of the syscalls of the command started by
+	// New, and of all its descendants, with ptrace.
+	// The number of calls, errors and time spent per syscall are reported
+	// on Msg at the end of the monitoring, in the fashion of 'strace -c'.
+	// Tracing syscalls slows down the monitored process(es) significantly.
+	Strace bool
+
+	// StraceLog enables the recording of the per-syscall statistics
+	// gathered during each sampling period, when Strace is enabled.
+	StraceLog bool
+
+	// Events enables the recording of the lifecycle events of the command
+	// started by New and of all its descendants, traced with ptrace: each
+	// fork/clone with its parent, each execve with the new command line,
+	// each exit with its code or signal, and the delivered signals.
+	Events bool
+
+	// Subreaper marks the current process as a child subreaper, so that
+	// the orphaned descendants of the command started by New (daemonized
+	// helpers, setsid'ed grandchildren...) are adopted by it, instead of
+	// init.
+	// The adopted descendants are monitored as part of the process tree,
+	// until they exit or Grace has elapsed.
+	// Subreaper implies Tree.
+	Subreaper bool
+
+	// Grace is the maximum time to wait for the descendants of the command
+	// started by New, after it has exited, in Subreaper, Strace or Events
+	// modes.
+	// The descendants still running after Grace are reported on Msg, and
+	// killed if KillOrphans is set.
+	// A zero value waits for all the descendants to exit.
+	Grace time.Duration
+
+	// KillOrphans enables the killing of the descendants still running
+	// after Grace.
+	KillOrphans bool
+
+	// Labels holds user-supplied key=value pairs, recorded in the log
+	// header to identify the run.
+	Labels map[string]string
+
+	// Rescan enables the re-resolution of the selector of the processes
+	// monitored by Select at each sample, so that matching processes
+	// started during the monitoring (e.g. restarted by a supervisor) are
+	// picked up.
+	// Without Rescan, the monitoring ends when all the processes selected
+	// at start have exited.
+	Rescan bool
+
+	quit   chan struct{}
+	detach chan struct{} // closed to end the monitoring, in attach mode
 
 	fc chan func() error
 	ec chan error
@@ -29,9 +154,22 @@ type Process struct {
 	Msg  *log.Logger
 	Cmd  *exec.Cmd
 	proc *os.Process
+	sel  *Selector // selector of the monitored processes, in selection mode
+
+	session *Session // session sampling the process, if any
 
 	start func() error
 	stop  func() error
+
+	offcpu  offCPU       // summary of the off-CPU samples
+	orphans *orphans     // descendants of the command, in Subreaper mode
+	tracer  *tracer      // ptrace tracer, in Strace or Events mode
+	exit    *ExitInfos   // exit status of the command started by New
+	exited  atomic.Bool  // whether the command started by New has been waited for
+	rss     atomic.Int64 // last sampled resident set size (kB)
+
+	mu    sync.Mutex // serializes the samplings and the end of the monitoring
+	ended bool       // whether the monitoring has ended
 }
 
 // New creates a new process named cmd and with the provided arguments.
@@ -55,22 +193,30 @@ func New(cmd string, args ...string) *Process {
 		fc:   make(chan func() error),
 		ec:   make(chan error),
 
-		start: c.Start,
-		stop: func() error {
-			pgid, err := sys.Getpgid(c.Process.Pid)
-			if err != nil {
-				return fmt.Errorf("could not get process group of pid=%d: %w", c.Process.Pid, err)
-			}
-			err = sys.Kill(-pgid, sys.SIGKILL) // note the minus sign
-			if err != nil {
-				return fmt.Errorf("could not kill process group %d: %w", pgid, err)
+		start:   c.Start,
+		orphans: newOrphans(),
+	}
+	proc.stop = func() error {
+		if proc.Subreaper {
+			// also kill the adopted descendants, which may have left
+			// the process group.
+			for _, pid := range proc.orphans.adopted() {
+				_ = sys.Kill(pid, sys.SIGKILL)
 			}
+		}
 
-			return nil
-		},
+		pgid, err := sys.Getpgid(c.Process.Pid)
+		if err != nil {
+			return fmt.Errorf("could not get process group of pid=%d: %w", c.Process.Pid, err)
+		}
+		err = sys.Kill(-pgid, sys.SIGKILL) // note the minus sign
+		if err != nil {
+			return fmt.Errorf("could not kill process group %d: %w", pgid, err)
+		}
+
+		return nil
 	}
 
-	go ptraceRun(proc.fc, proc.ec)
 	return proc
 }
 
@@ -79,6 +225,8 @@ func (p *Process) Run() error {
 	switch {
 	case p.Cmd != nil:
 		return p.runCmd()
+	case p.sel != nil:
+		return p.runSelect()
 	default:
 		return p.runPID()
 	}
@@ -92,7 +240,25 @@ func (p *Process) runCmd() error {
 		}
 	}()
 
-	err := p.ptraceRun(p.Cmd.Start)
+	if p.Subreaper {
+		err := setSubreaper()
+		if err != nil {
+			return fmt.Errorf("could not become a child subreaper: %w", err)
+		}
+	}
+
+	// the command is only started, traced and waited for from a dedicated
+	// thread when its syscalls or lifecycle events are traced.
+	tracing := p.Strace || p.Events
+	p.Cmd.SysProcAttr.Ptrace = tracing
+	run := p.Cmd.Start
+	if tracing {
+		go ptraceRun(p.fc, p.ec)
+		defer close(p.fc)
+		run = func() error { return p.ptraceRun(p.Cmd.Start) }
+	}
+
+	err := run()
 	if err != nil {
 		return fmt.Errorf("could not start process: %w", err)
 	}
@@ -100,7 +266,8 @@ func (p *Process) runCmd() error {
 	start := time.Now()
 
 	pid := p.Cmd.Process.Pid
-	collector, err := newCollector(p.Msg, pid)
+	p.orphans.track(pid)
+	collector, err := newCollector(p.Msg, pid, p.config())
 	if err != nil {
 		return fmt.Errorf("could not create collector: %w", err)
 	}
@@ -116,8 +283,19 @@ func (p *Process) runCmd() error {
 	if err != nil {
 		return fmt.Errorf("error writing log-file header: %w", err)
 	}
+	p.writeEnv()
 
+	var oom *oomWatch
 	defer func() {
+		p.end()
+		p.offcpu.summary(p.Msg)
+		if p.tracer != nil {
+			p.writeEvents()
+			p.tracer.summary(p.Msg)
+		}
+		if p.exit != nil && p.exit.Signal == "SIGKILL" && oom != nil {
+			p.checkOOM(oom, pid, start)
+		}
 		stop := time.Now()
 		delta := time.Since(start)
 		_, _ = fmt.Fprintf(p.W,
@@ -125,16 +303,32 @@ func (p *Process) runCmd() error {
 			delta,
 			stop.Format(time.RFC3339Nano),
 		)
+		if p.exit != nil {
+			p.writeExit()
+		}
 	}()
 
-	err = p.wait(pid, 0)
-	if err != nil {
-		return fmt.Errorf("waiting for target execve failed: %w", err)
+	if tracing {
+		// the traced command stops after its execve.
+		err = p.wait(pid, 0)
+		if err != nil {
+			return fmt.Errorf("waiting for target execve failed: %w", err)
+		}
 	}
+	oom = newOOMWatch(pid)
 
-	err = p.ptraceDetach(pid)
-	if err != nil {
-		return fmt.Errorf("could not ptrace-detach pid=%d: %w", pid, err)
+	var traced chan error
+	if tracing {
+		p.tracer = newTracer(pid, start, p.Strace, p.Events)
+		err = p.ptraceRun(p.tracer.attach)
+		if err != nil {
+			_ = p.stop()
+			return fmt.Errorf("could not trace pid=%d: %w", pid, err)
+		}
+		traced = make(chan error, 1)
+		go func() {
+			traced <- p.ptraceRun(p.tracer.run)
+		}()
 	}
 
 	go p.monitor(collector)
@@ -144,7 +338,39 @@ func (p *Process) runCmd() error {
 		p.Cmd.Process.Pid,
 		p.Freq,
 	)
+
+	if p.tracer != nil {
+		// the tracer reaps the monitored command and its descendants.
+		err = p.waitTracer(traced)
+		_ = p.Cmd.Process.Release()
+		if p.tracer.exited.Load() {
+			p.exit = newExitInfos(p.tracer.status, &p.tracer.rusage)
+		}
+		if err != nil {
+			return fmt.Errorf("could not trace pid=%d: %w", pid, err)
+		}
+		if p.exit != nil && p.exit.Status() != 0 {
+			return fmt.Errorf("could not wait for pid=%d: %w", pid, &ExitError{p.exit})
+		}
+		return nil
+	}
+
+	if p.session != nil {
+		// do not block a thread in Cmd.Wait until the command exits.
+		<-p.watchExit(pid)
+	}
 	err = p.Cmd.Wait()
+	p.exited.Store(true)
+	if ps := p.Cmd.ProcessState; ps != nil {
+		p.exit = newExitInfos(ps.Sys().(syscall.WaitStatus), ps.SysUsage().(*syscall.Rusage))
+	}
+	if p.Subreaper {
+		p.waitOrphans()
+	}
+	var eerr *exec.ExitError
+	if errors.As(err, &eerr) && p.exit != nil {
+		err = &ExitError{p.exit}
+	}
 	if err != nil {
 		return fmt.Errorf("could not wait for pid=%d: %w", pid, err)
 	}
@@ -152,19 +378,118 @@ func (p *Process) runCmd() error {
 	return nil
 }
 
+// reapFreq is the polling period of the descendants of the monitored
+// command, after it has exited.
+const reapFreq = 100 * time.Millisecond
+
+// waitTracer waits for the tracer to return, i.e. for the monitored command
+// and all its traced descendants to exit.
+// The descendants still running Grace after the command exited are killed
+// or detached from.
+func (p *Process) waitTracer(traced chan error) error {
+	tick := time.NewTicker(reapFreq)
+	defer tick.Stop()
+
+	var deadline time.Time
+	for {
+		select {
+		case err := <-traced:
+			return err
+		case now := <-tick.C:
+			if p.Grace <= 0 || !p.tracer.exited.Load() {
+				continue
+			}
+			if deadline.IsZero() {
+				deadline = now.Add(p.Grace)
+			}
+			if now.Before(deadline) {
+				continue
+			}
+			p.leftovers(p.tracer.leftovers())
+			if !p.KillOrphans {
+				p.tracer.detach()
+			}
+			return <-traced
+		}
+	}
+}
+
+// waitOrphans waits for the descendants adopted by the current process, as
+// a child subreaper, to exit and reaps them.
+// The descendants still running after Grace are killed or left running.
+func (p *Process) waitOrphans() {
+	tick := time.NewTicker(reapFreq)
+	defer tick.Stop()
+
+	var (
+		deadline = time.Now().Add(p.Grace)
+		reported = make(map[int]bool) // leftovers already reported (and killed)
+	)
+	for {
+		orphans := p.orphans.reap()
+		if len(orphans) == 0 {
+			return
+		}
+		if p.Grace > 0 && time.Now().After(deadline) {
+			var pids []int
+			for _, pid := range orphans {
+				if !reported[pid] {
+					reported[pid] = true
+					pids = append(pids, pid)
+				}
+			}
+			p.leftovers(pids)
+			if !p.KillOrphans {
+				return
+			}
+		}
+		<-tick.C
+	}
+}
+
+// leftovers reports the provided descendants, still running after Grace,
+// and kills them if KillOrphans is set.
+func (p *Process) leftovers(pids []int) {
+	for _, pid := range pids {
+		switch {
+		case p.KillOrphans:
+			p.Msg.Printf("killing leftover process pid=%d (%s)", pid, comm(pid))
+			_ = sys.Kill(pid, sys.SIGKILL)
+		default:
+			p.Msg.Printf("leaving leftover process pid=%d (%s) running", pid, comm(pid))
+		}
+	}
+}
+
 func (p *Process) runPID() error {
+	defer close(p.quit)
+	defer func() {
+		if w, ok := p.W.(interface{ Flush() error }); ok {
+			_ = w.Flush()
+		}
+	}()
+
 	start := time.Now()
 
 	pid := p.proc.Pid
-	collector, err := newCollector(p.Msg, pid)
+	collector, err := newCollector(p.Msg, pid, p.config())
 	if err != nil {
 		return fmt.Errorf("could not create collector: %w", err)
 	}
 	defer collector.Close()
 
+	target, err := readTarget(pid)
+	if err != nil {
+		p.Msg.Printf("could not read metadata of pid=%d: %+v", pid, err)
+	}
+	cmdline := strings.Join(target.Argv, " ")
+	if cmdline == "" {
+		cmdline = "<N/A>"
+	}
+
 	_, err = fmt.Fprintf(p.W,
 		"# pmon: %s\n# freq: %v\n# format: %#v\n# start: %v\n",
-		p.cmdline(pid),
+		cmdline,
 		p.Freq,
 		Infos{},
 		start.Format(time.RFC3339Nano),
@@ -172,8 +497,12 @@ func (p *Process) runPID() error {
 	if err != nil {
 		return fmt.Errorf("error writing log-file header: %w", err)
 	}
+	p.writeEnv()
+	p.writeTarget(target)
 
 	defer func() {
+		p.end()
+		p.offcpu.summary(p.Msg)
 		stop := time.Now()
 		delta := time.Since(start)
 		_, _ = fmt.Fprintf(p.W,
@@ -183,6 +512,7 @@ func (p *Process) runPID() error {
 		)
 	}()
 
+	exited := p.watchExit(pid)
 	go p.monitor(collector)
 
 	p.Msg.Printf(
@@ -190,20 +520,274 @@ func (p *Process) runPID() error {
 		p.proc.Pid,
 		p.Freq,
 	)
-	<-p.quit
+	select {
+	case <-exited:
+		p.Msg.Printf("pid=%d exited", pid)
+	case <-p.detach:
+		p.Msg.Printf("detaching from pid=%d", pid)
+	}
+
+	return nil
+}
+
+func (p *Process) runSelect() error {
+	defer close(p.quit)
+	defer func() {
+		if w, ok := p.W.(interface{ Flush() error }); ok {
+			_ = w.Flush()
+		}
+	}()
+
+	start := time.Now()
+
+	pids, err := selectProcs(p.sel)
+	if err != nil {
+		return fmt.Errorf("could not select processes: %w", err)
+	}
+	if len(pids) == 0 && !p.Rescan {
+		return fmt.Errorf("no process matching %v", p.sel)
+	}
+
+	cfg := p.config()
+	cfg.pids = pids
+	collector, err := newCollector(p.Msg, 0, cfg)
+	if err != nil {
+		return fmt.Errorf("could not create collector: %w", err)
+	}
+	defer collector.Close()
+
+	_, err = fmt.Fprintf(p.W,
+		"# pmon: %v\n# freq: %v\n# format: %#v\n# start: %v\n",
+		p.sel,
+		p.Freq,
+		Infos{},
+		start.Format(time.RFC3339Nano),
+	)
 	if err != nil {
-		return fmt.Errorf("could not wait for pid=%d: %w", pid, err)
+		return fmt.Errorf("error writing log-file header: %w", err)
+	}
+	p.writeEnv()
+	_, _ = fmt.Fprintf(p.W, "# select: %v\n# rescan: %v\n", p.sel, p.Rescan)
+
+	defer func() {
+		p.end()
+		p.offcpu.summary(p.Msg)
+		stop := time.Now()
+		delta := time.Since(start)
+		_, _ = fmt.Fprintf(p.W,
+			"# elapsed: %v\n# stop: %v\n",
+			delta,
+			stop.Format(time.RFC3339Nano),
+		)
+	}()
+
+	// without rescan, the monitoring ends when all the selected processes
+	// have exited. otherwise, it only ends with Kill.
+	var exited <-chan struct{}
+	if !p.Rescan {
+		exited = p.watchAll(pids)
+	}
+	go p.monitor(collector)
+
+	p.Msg.Printf(
+		"monitoring... (%v, pids=%v, freq=%v)\n",
+		p.sel,
+		pids,
+		p.Freq,
+	)
+	select {
+	case <-exited:
+		p.Msg.Printf("selected processes exited")
+	case <-p.detach:
+		p.Msg.Printf("detaching from selected processes")
 	}
 
 	return nil
 }
 
+// watchExit returns a channel closed when the process with the provided pid
+// exits, or when the monitoring ends.
+// The processes of a session are watched on the shared ticker of the
+// session.
+func (p *Process) watchExit(pid int) <-chan struct{} {
+	if p.session != nil {
+		return p.session.watch(pid, p.quit)
+	}
+	return watchExit(pid, p.quit)
+}
+
+// watchAll returns a channel closed once all the provided processes have
+// exited.
+func (p *Process) watchAll(pids []int) <-chan struct{} {
+	quit := p.quit
+	exits := make([]<-chan struct{}, len(pids))
+	for i, pid := range pids {
+		exits[i] = p.watchExit(pid)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for _, exited := range exits {
+			select {
+			case <-exited:
+			case <-quit:
+				return
+			}
+		}
+		close(done)
+	}()
+	return done
+}
+
+// checkOOM records an oom-killed event if the OOM killer was responsible
+// for the SIGKILL of the command, with its last sampled RSS and the memory
+// limit that was hit.
+// OOM kills that may have hit another process are reported as probable.
+func (p *Process) checkOOM(oom *oomWatch, pid int, start time.Time) {
+	rss := p.rss.Load()
+	killed, certain, limit, source := oom.check(rss)
+	if !killed {
+		return
+	}
+	detail := fmt.Sprintf("limit=%d kB (%s)", limit, source)
+	switch {
+	case certain:
+		p.Msg.Printf(
+			"pid=%d was killed by the OOM killer (rss=%d kB, limit=%d kB from %s)",
+			pid, rss, limit, source,
+		)
+	default:
+		p.Msg.Printf(
+			"pid=%d was oom-killed (probable) (rss=%d kB, limit=%d kB from %s)",
+			pid, rss, limit, source,
+		)
+		detail = "(probable) " + detail
+	}
+	fmt.Fprintf(
+		p.W, "event: %f %s %d %d %q\n",
+		milliseconds(time.Since(start)), "oom-killed", pid, rss, detail,
+	)
+}
+
+// Exit returns the exit status and resources usage of the command started
+// by New, once it has exited.
+// Exit returns nil in attach mode, or if the command has not exited.
+func (p *Process) Exit() *ExitInfos {
+	return p.exit
+}
+
+// ExitError is the error returned by Run when the command started by New
+// exits unsuccessfully.
+type ExitError struct {
+	*ExitInfos
+}
+
+func (e *ExitError) Error() string {
+	return e.ExitInfos.String()
+}
+
+// writeExit writes the exit status and resources usage of the command in the
+// log footer.
+func (p *Process) writeExit() {
+	_, _ = fmt.Fprintf(p.W, "# exit-code: %d\n", p.exit.Code)
+	if p.exit.Signal != "" {
+		_, _ = fmt.Fprintf(p.W,
+			"# signal: %s\n# core-dump: %v\n",
+			p.exit.Signal, p.exit.CoreDump,
+		)
+	}
+	_, _ = fmt.Fprintf(p.W, "# rusage: %v\n", p.exit.Rusage)
+}
+
+// newExitInfos returns the exit infos of a process, from its wait status and
+// resources usage.
+func newExitInfos(ws syscall.WaitStatus, ru *syscall.Rusage) *ExitInfos {
+	exit := ExitInfos{
+		Code: ws.ExitStatus(),
+		Rusage: Rusage{
+			UTime:    time.Duration(ru.Utime.Nano()),
+			STime:    time.Duration(ru.Stime.Nano()),
+			MaxRSS:   maxRSS(ru),
+			IxRSS:    int64(ru.Ixrss),
+			IdRSS:    int64(ru.Idrss),
+			IsRSS:    int64(ru.Isrss),
+			MinFlt:   int64(ru.Minflt),
+			MajFlt:   int64(ru.Majflt),
+			NSwap:    int64(ru.Nswap),
+			InBlock:  int64(ru.Inblock),
+			OuBlock:  int64(ru.Oublock),
+			MsgSnd:   int64(ru.Msgsnd),
+			MsgRcv:   int64(ru.Msgrcv),
+			NSignals: int64(ru.Nsignals),
+			NVCSw:    int64(ru.Nvcsw),
+			NIVCSw:   int64(ru.Nivcsw),
+		},
+	}
+	if ws.Signaled() {
+		exit.Signal = sys.SignalName(ws.Signal())
+		exit.CoreDump = ws.CoreDump()
+	}
+	return &exit
+}
+
+// writeEnv writes the metadata of the host and of the pmon build, and the
+// labels of the run, in the log header.
+func (p *Process) writeEnv() {
+	env := readEnv()
+	_, _ = fmt.Fprintf(p.W,
+		"# env-hostname: %s\n# env-kernel: %s\n# env-cpu-model: %s\n# env-cpus: %d\n"+
+			"# env-mem-total: %d\n# env-page-size: %d\n# env-clock-ticks: %d\n"+
+			"# env-version: %s\n# env-go-version: %s\n",
+		env.Hostname, env.Kernel, env.CPUModel, env.CPUs,
+		env.MemTotal, env.PageSize, env.ClockTicks,
+		env.Version, env.GoVersion,
+	)
+
+	keys := make([]string, 0, len(p.Labels))
+	for k := range p.Labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(p.W, "# label: %s=%s\n", k, p.Labels[k])
+	}
+}
+
+// writeTarget writes the metadata of the monitored process in the log
+// header.
+func (p *Process) writeTarget(target TargetInfos) {
+	argv := make([]string, len(target.Argv))
+	for i, arg := range target.Argv {
+		argv[i] = strconv.Quote(arg)
+	}
+	_, _ = fmt.Fprintf(p.W,
+		"# target-pid: %d\n# target-argv: %s\n# target-exe: %s\n# target-cwd: %s\n"+
+			"# target-uid: %d %d\n# target-gid: %d %d\n",
+		target.PID, strings.Join(argv, " "), target.Exe, target.Cwd,
+		target.UID, target.EUID, target.GID, target.EGID,
+	)
+	if !target.Started.IsZero() {
+		_, _ = fmt.Fprintf(p.W, "# target-started: %v\n", target.Started.Format(time.RFC3339Nano))
+	}
+	_, _ = fmt.Fprintf(p.W,
+		"# target-cgroup: %s\n# target-namespaces: %s\n",
+		target.Cgroup, strings.Join(target.Namespaces, " "),
+	)
+}
+
 // Kill causes the monitored process to exit immediately.
+// In attach mode, Kill ends the monitoring and leaves the process running.
 func (p *Process) Kill() error {
 	return p.stop()
 }
 
 func (p *Process) monitor(c *collector) {
+	if p.session != nil {
+		// sampled on the shared ticker of the session.
+		p.session.schedule(p, c)
+		return
+	}
+
 	p.collect(c)
 	tick := time.Tick(p.Freq)
 	for {
@@ -216,29 +800,309 @@ func (p *Process) monitor(c *collector) {
 	}
 }
 
+// errExited is returned when all the monitored processes have exited, but
+// have not been reaped yet.
+var errExited = errors.New("monitored processes exited")
+
+// end ends the sampling of the monitored process(es), waiting for the
+// sampling in progress, if any, so that the log footer can be written.
+func (p *Process) end() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ended = true
+}
+
 func (p *Process) collect(c *collector) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	if p.Cmd != nil && p.Cmd.ProcessState != nil {
+	switch {
+	case p.ended:
+		// monitoring already ended. nothing to collect.
+		return
+	case p.Cmd == nil, p.Subreaper:
+		// attached process, or adopted descendants still monitored.
+	case p.exited.Load():
 		// process already stopped. nothing to collect.
 		return
+	case p.tracer != nil && p.tracer.exited.Load() && !p.Tree:
+		// process already reaped by the tracer. nothing to collect.
+		return
 	}
 
-	infos, err := c.collect()
-	if err != nil {
+	s, err := c.collect()
+	switch {
+	case errors.Is(err, errNoMatch), errors.Is(err, errExited):
+		// no selected process currently running, or not reaped yet.
+		return
+	case err != nil:
 		p.Msg.Printf("error collecting: %+v", err)
 		return
 	}
 
-	fmt.Fprintf(
-		p.W, "%f %f %f %d %d %d %d %d %d %d\n",
-		milliseconds(infos.CPU), milliseconds(infos.UTime), milliseconds(infos.STime),
-		infos.VMem, infos.RSS,
-		infos.Threads,
-		infos.Rchar, infos.Wchar,
-		infos.Rdisk, infos.Wdisk,
+	fmt.Fprintf(p.W, infosFmt+"\n", s.Infos.args()...)
+	p.rss.Store(s.RSS)
+
+	for _, v := range s.procs {
+		fmt.Fprintf(
+			p.W, "proc: %d %d %q %f %d %d %d %d %d\n",
+			v.PID, v.PPID, v.Comm,
+			milliseconds(v.CPU), v.RSS,
+			v.Rchar, v.Wchar,
+			v.Rdisk, v.Wdisk,
+		)
+	}
+
+	for _, v := range s.threads {
+		fmt.Fprintf(
+			p.W, "thread: %d %q %c %f %f\n",
+			v.TID, v.Name, v.State,
+			milliseconds(v.UTime), milliseconds(v.STime),
+		)
+	}
+
+	if v := s.smaps; v != nil {
+		fmt.Fprintf(
+			p.W, "smaps: %d %d %d %d\n",
+			v.PSS, v.USS, v.Swap, v.SwapPSS,
+		)
+	}
+
+	for _, v := range s.maps {
+		fmt.Fprintf(
+			p.W, "map: %q %d %d %d %d\n",
+			v.Path, v.RSS, v.PSS, v.Private, v.AnonHuge,
+		)
+	}
+
+	if v := s.fds; v != nil {
+		fmt.Fprintf(
+			p.W, "fds: %d %d %d %d %d %d %d %d %d\n",
+			v.Total, v.Files, v.Pipes, v.Sockets,
+			v.EventFDs, v.AnonInodes, v.Others,
+			v.Peak, v.Limit,
+		)
+	}
+
+	for _, v := range s.socks {
+		fmt.Fprintf(p.W, "sock: %s %s %d\n", v.Proto, v.State, v.N)
+	}
+
+	for _, v := range s.files {
+		fmt.Fprintf(
+			p.W, "file: %d %d %d %d %s %q\n",
+			v.PID, v.FD, v.Pos, v.Size, v.Mode, v.Path,
+		)
+	}
+
+	if v := s.cgroup; v != nil {
+		fmt.Fprintf(
+			p.W, "cgroup: %d %d %d %d %d %d %d %d %f %d %f %d %d %d %d %q\n",
+			v.Memory, v.Anon, v.File, v.Kernel, v.Sock,
+			v.High, v.OOM, v.OOMKill,
+			milliseconds(v.CPU), v.NrThrottled, milliseconds(v.Throttled),
+			v.Rdisk, v.Wdisk, v.Rios, v.Wios,
+			v.Path,
+		)
+	}
+
+	for _, v := range s.psi {
+		fmt.Fprintf(
+			p.W, "psi: %s %s %f %f %f %f\n",
+			v.Source, v.Resource,
+			v.SomeAvg10, milliseconds(v.SomeTotal),
+			v.FullAvg10, milliseconds(v.FullTotal),
+		)
+	}
+
+	if v := s.host; v != nil {
+		fmt.Fprintf(
+			p.W, "host: %f %f %f %d %d %d %d %d %f %f %f %f %d %d %d %d\n",
+			v.Load1, v.Load5, v.Load15,
+			v.MemAvailable, v.Cached, v.Dirty, v.Writeback, v.SwapFree,
+			milliseconds(v.Busy), milliseconds(v.IOWait), milliseconds(v.Steal), milliseconds(v.Total),
+			v.Rdisk, v.Wdisk,
+			v.NetRx, v.NetTx,
+		)
+	}
+
+	if v := s.states; v != nil {
+		fmt.Fprintf(
+			p.W, "states: %d %d %d %d\n",
+			v.Running, v.Sleeping, v.Disk, v.Other,
+		)
+		p.offcpu.add(s)
+	}
+
+	for _, v := range s.wchans {
+		fmt.Fprintf(p.W, "wchan: %q %d\n", v.Name, v.N)
+	}
+
+	for _, v := range s.syscalls {
+		fmt.Fprintf(p.W, "syscall: %d %q %d\n", v.Nr, v.Name, v.N)
+	}
+
+	if p.tracer != nil && p.StraceLog {
+		for _, v := range p.tracer.interval() {
+			fmt.Fprintf(
+				p.W, "strace: %d %q %d %d %f\n",
+				v.Nr, v.Name, v.Calls, v.Errors, milliseconds(v.Time),
+			)
+		}
+	}
+
+	if p.tracer != nil {
+		p.writeEvents()
+	}
+}
+
+// writeEvents writes the lifecycle events recorded by the tracer since the
+// last call to writeEvents.
+func (p *Process) writeEvents() {
+	for _, v := range p.tracer.events() {
+		fmt.Fprintf(
+			p.W, "event: %f %s %d %d %q\n",
+			milliseconds(v.Time), v.Kind, v.PID, v.Value, v.Detail,
+		)
+	}
+}
+
+// offCPU holds the summary of the off-CPU samples of a run.
+type offCPU struct {
+	mu       sync.Mutex
+	states   StateInfos       // number of threads per scheduler state, summed over all samples
+	wchans   map[string]int64 // number of threads per kernel wait channel, summed over all samples
+	syscalls map[string]int64 // number of threads per blocking syscall, summed over all samples
+}
+
+func (o *offCPU) add(s sample) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.wchans == nil {
+		o.wchans = make(map[string]int64)
+		o.syscalls = make(map[string]int64)
+	}
+	o.states.Running += s.states.Running
+	o.states.Sleeping += s.states.Sleeping
+	o.states.Disk += s.states.Disk
+	o.states.Other += s.states.Other
+	for _, v := range s.wchans {
+		o.wchans[v.Name] += v.N
+	}
+	for _, v := range s.syscalls {
+		o.syscalls[v.Name] += v.N
+	}
+}
+
+// summary reports the off-CPU summary on msg.
+func (o *offCPU) summary(msg *log.Logger) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	tot := o.states.Running + o.states.Sleeping + o.states.Disk + o.states.Other
+	if tot == 0 {
+		return
+	}
+	pct := func(n int64) float64 { return float64(n) / float64(tot) * 100 }
+	msg.Printf(
+		"threads states: running=%.1f%% sleeping=%.1f%% uninterruptible=%.1f%% other=%.1f%%",
+		pct(o.states.Running), pct(o.states.Sleeping), pct(o.states.Disk), pct(o.states.Other),
 	)
+
+	const top = 10
+	for _, hist := range []struct {
+		name string
+		vs   map[string]int64
+	}{
+		{"wait channels", o.wchans},
+		{"blocking syscalls", o.syscalls},
+	} {
+		keys := make([]string, 0, len(hist.vs))
+		for k := range hist.vs {
+			keys = append(keys, k)
+		}
+		slices.SortFunc(keys, func(a, b string) int {
+			if o := cmp.Compare(hist.vs[b], hist.vs[a]); o != 0 {
+				return o
+			}
+			return cmp.Compare(a, b)
+		})
+		if len(keys) > top {
+			keys = keys[:top]
+		}
+		msg.Printf("top %s:", hist.name)
+		for _, k := range keys {
+			msg.Printf("  %-24s %5.1f%%", k, pct(hist.vs[k]))
+		}
+	}
+}
+
+// config holds the configuration of a collector.
+type config struct {
+	tree      bool          // whether to monitor the whole process tree
+	breakdown bool          // whether to record per-process infos
+	threads   bool          // whether to record per-thread infos
+	smaps     time.Duration // sampling period of smaps_rollup
+	maps      time.Duration // sampling period of smaps
+	mapsTop   int           // number of mappings to record
+	fds       bool          // whether to record the file descriptors inventory
+	progress  time.Duration // sampling period of files positions
+	cgroup    bool          // whether to record cgroup v2 metrics
+	psi       bool          // whether to record pressure stall information
+	host      bool          // whether to record system-wide resources usage
+	offcpu    bool          // whether to record threads states, wait channels and syscalls
+	subreaper bool          // whether adopted processes belong to the process tree
+	orphans   *orphans      // descendants of the command, in Subreaper mode
+	sel       *Selector     // selector of the monitored processes, if any
+	rescan    bool          // whether to select new matching processes at each sample
+	pids      []int         // processes selected at start
+}
+
+func (p *Process) config() config {
+	return config{
+		tree:      p.Tree,
+		breakdown: p.Breakdown,
+		threads:   p.Threads,
+		smaps:     p.SmapsFreq,
+		maps:      p.MapsFreq,
+		mapsTop:   p.MapsTop,
+		fds:       p.FDs,
+		progress:  p.ProgressFreq,
+		cgroup:    p.Cgroup,
+		psi:       p.PSI,
+		host:      p.Host,
+		offcpu:    p.OffCPU,
+		subreaper: p.Subreaper,
+		orphans:   p.orphans,
+		sel:       p.sel,
+		rescan:    p.Rescan,
+	}
+}
+
+// sample holds the data gathered by a collector during one tick.
+type sample struct {
+	Infos
+	procs   []ProcInfos   // per-process infos, in breakdown mode
+	threads []ThreadInfos // per-thread infos
+	smaps   *SmapsInfos   // proportional and unique memory usage, if sampled
+	maps    []MapsInfos   // memory usage of the largest mappings, if sampled
+	fds     *FDInfos      // file descriptors inventory
+	socks   []SockInfos   // number of sockets per protocol and state
+	files   []FileInfos   // positions in the opened regular files, if sampled
+	cgroup  *CgroupInfos  // cgroup v2 metrics
+	psi     []PSIInfos    // pressure stall information
+	host    *HostInfos    // system-wide resources usage
+
+	states   *StateInfos    // number of threads per scheduler state
+	wchans   []WchanInfos   // number of threads per kernel wait channel
+	syscalls []SyscallInfos // number of threads per blocking syscall
 }
 
 func milliseconds(t time.Duration) float64 {
 	return t.Seconds() * 1e3
 }
+
+func fromMilliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
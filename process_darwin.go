@@ -5,6 +5,8 @@
 package pmon
 
 import (
+	"syscall"
+
 	sys "golang.org/x/sys/unix"
 )
 
@@ -17,7 +19,19 @@ func (p *Process) wait(pid, options int) error {
 	return <-p.ec
 }
 
-func (p *Process) cmdline(pid int) string {
-	// TODO(sbinet)
-	return "<N/A>"
+// maxRSS returns the maximum resident set size of the provided resources
+// usage, in kB.
+func maxRSS(ru *syscall.Rusage) int64 {
+	return int64(ru.Maxrss) / 1024
+}
+
+// watchExit returns a channel closed when the process with the provided pid
+// exits, or when quit is closed.
+func watchExit(pid int, quit <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		probeExit(pid, quit)
+	}()
+	return done
 }
@@ -5,8 +5,7 @@
 package pmon
 
 import (
-	"fmt"
-	"os"
+	"syscall"
 
 	sys "golang.org/x/sys/unix"
 )
@@ -20,10 +19,40 @@ func (p *Process) wait(pid, options int) error {
 	return <-p.ec
 }
 
-func (p *Process) cmdline(pid int) string {
-	cmd, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
-	if err != nil {
-		return "<N/A>"
-	}
-	return string(cmd)
+// maxRSS returns the maximum resident set size of the provided resources
+// usage, in kB.
+func maxRSS(ru *syscall.Rusage) int64 {
+	return int64(ru.Maxrss)
+}
+
+// watchExit returns a channel closed when the process with the provided pid
+// exits, or when quit is closed.
+// The process is watched with a pidfd, or probed periodically on kernels
+// without pidfd_open(2).
+func watchExit(pid int, quit <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fd, err := sys.PidfdOpen(pid, 0)
+		if err != nil {
+			probeExit(pid, quit)
+			return
+		}
+		defer sys.Close(fd)
+
+		fds := []sys.PollFd{{Fd: int32(fd), Events: sys.POLLIN}}
+		for {
+			// a pidfd becomes readable when its process exits.
+			n, err := sys.Poll(fds, int(reapFreq.Milliseconds()))
+			if err == nil && n > 0 {
+				return
+			}
+			select {
+			case <-quit:
+				return
+			default:
+			}
+		}
+	}()
+	return done
 }
@@ -0,0 +1,74 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// TestRunParse checks that the log written while monitoring a command is
+// parsed back.
+func TestRunParse(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := New("sh", "-c", "sleep 0.2; exit 3")
+	p.Cmd.Stdin = nil
+	p.Cmd.Stdout = nil
+	p.Cmd.Stderr = nil
+	p.W = buf
+	p.Msg = log.New(io.Discard, "", 0)
+	p.Freq = 20 * time.Millisecond
+	p.Tree = true
+	p.FDs = true
+	p.Labels = map[string]string{"test": "run"}
+
+	var eerr *ExitError
+	err := p.Run()
+	if !errors.As(err, &eerr) {
+		t.Fatalf("expected an exit error for a failing command: %+v", err)
+	}
+	exit := p.Exit()
+	if exit == nil || exit.Code != 3 || eerr.ExitInfos != exit {
+		t.Fatalf("invalid exit: %+v", exit)
+	}
+
+	meta, err := Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("could not parse log:\n%s\nerror: %+v", buf.Bytes(), err)
+	}
+	if got, want := meta.Cmd, "sh -c sleep 0.2; exit 3"; got != want {
+		t.Errorf("invalid command: got=%q, want=%q", got, want)
+	}
+	if got, want := meta.Freq, p.Freq; got != want {
+		t.Errorf("invalid frequency: got=%v, want=%v", got, want)
+	}
+	if got, want := meta.Labels["test"], "run"; got != want {
+		t.Errorf("invalid label: got=%q, want=%q", got, want)
+	}
+	if meta.Env.PageSize != PageSize || meta.Env.CPUs <= 0 {
+		t.Errorf("invalid env: %+v", meta.Env)
+	}
+	if meta.Exit == nil || *meta.Exit != *exit {
+		t.Errorf("invalid exit: got=%+v, want=%+v", meta.Exit, exit)
+	}
+	if len(meta.Infos) == 0 {
+		t.Fatalf("no sample")
+	}
+	if len(meta.FDs) != len(meta.Infos) {
+		t.Errorf("invalid number of fds samples: got=%d, want=%d", len(meta.FDs), len(meta.Infos))
+	}
+	for i, v := range meta.Infos {
+		if v.RSS <= 0 || v.Threads <= 0 || v.RSSPeak < v.RSS {
+			t.Errorf("invalid sample %d: %+v", i, v)
+		}
+	}
+	if meta.Elapsed < 200*time.Millisecond {
+		t.Errorf("invalid elapsed time: %v", meta.Elapsed)
+	}
+}
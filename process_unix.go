@@ -5,18 +5,39 @@
 package pmon
 
 import (
+	"errors"
 	"os"
+	"syscall"
+	"time"
 )
 
 const (
 	// ClockTicks is the number of clock ticks per second.
-	// ClockTicks is a constant on Linux and Darwin.
+	// ClockTicks is a constant on Linux and Darwin, used when the number
+	// of clock ticks of the kernel can not be read.
 	ClockTicks = uint64(100) // uint64(C.sysconf(C._SC_CLK_TCK))
-
-	clockTicksToNanosecond = (1000000000 / ClockTicks)
 )
 
 var (
 	// PageSize is the underlying system's memory page size.
 	PageSize = int64(os.Getpagesize())
+
+	clockTicksToNanosecond = 1000000000 / uint64(clockTicks())
 )
+
+// probeExit blocks until the process with the provided pid exits, or until
+// quit is closed, by periodically probing the process with a null signal.
+func probeExit(pid int, quit <-chan struct{}) {
+	tick := time.NewTicker(reapFreq)
+	defer tick.Stop()
+	for {
+		if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+			return
+		}
+		select {
+		case <-tick.C:
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	sys "golang.org/x/sys/unix"
+)
+
+// fileKey identifies a file opened by a monitored process.
+type fileKey struct {
+	pid  int
+	fd   int
+	path string
+}
+
+// fileProgress holds the last recorded position in a file.
+type fileProgress struct {
+	pos  int64
+	time time.Time
+}
+
+// collectFiles collects the read/write positions in the regular files
+// opened by the monitored processes, from /proc/<pid>/fdinfo/<fd>.
+// The throughput and estimated completion time of the largest file opened
+// for reading are reported on the collector logger.
+func (c *collector) collectFiles(now time.Time) []FileInfos {
+	var files []FileInfos
+	for _, pid := range c.pids() {
+		dir := "/proc/" + strconv.Itoa(pid)
+		ents, err := os.ReadDir(dir + "/fd")
+		if err != nil {
+			c.msg.Printf("could not read fds of pid=%d: %+v", pid, err)
+			continue
+		}
+		for _, ent := range ents {
+			fd, err := strconv.Atoi(ent.Name())
+			if err != nil {
+				continue
+			}
+			fname := dir + "/fd/" + ent.Name()
+			fi, err := os.Stat(fname)
+			if err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			path, err := os.Readlink(fname)
+			if err != nil {
+				continue
+			}
+			raw, err := os.ReadFile(dir + "/fdinfo/" + ent.Name())
+			if err != nil {
+				continue
+			}
+			pos, flags, err := parseFDInfo(raw)
+			if err != nil {
+				c.msg.Printf("could not parse fdinfo %d of pid=%d: %+v", fd, pid, err)
+				continue
+			}
+			files = append(files, FileInfos{
+				PID:  pid,
+				FD:   fd,
+				Pos:  pos,
+				Size: fi.Size(),
+				Mode: accessMode(flags),
+				Path: path,
+			})
+		}
+	}
+
+	slices.SortFunc(files, func(a, b FileInfos) int {
+		if o := cmp.Compare(a.PID, b.PID); o != 0 {
+			return o
+		}
+		return cmp.Compare(a.FD, b.FD)
+	})
+
+	c.reportProgress(files, now)
+	return files
+}
+
+// reportProgress reports the throughput and estimated completion time of
+// the largest file opened for reading.
+func (c *collector) reportProgress(files []FileInfos, now time.Time) {
+	var (
+		input *FileInfos
+		progs = make(map[fileKey]fileProgress, len(files))
+	)
+	for i, f := range files {
+		progs[fileKey{f.PID, f.FD, f.Path}] = fileProgress{pos: f.Pos, time: now}
+		if f.Mode != "r" {
+			continue
+		}
+		if input == nil || f.Size > input.Size {
+			input = &files[i]
+		}
+	}
+	defer func() { c.files.progs = progs }()
+
+	if input == nil || input.Size <= 0 {
+		return
+	}
+
+	prev, ok := c.files.progs[fileKey{input.PID, input.FD, input.Path}]
+	if !ok || !now.After(prev.time) {
+		return
+	}
+
+	var (
+		done = float64(input.Pos) / float64(input.Size) * 100
+		rate = float64(input.Pos-prev.pos) / now.Sub(prev.time).Seconds() // bytes/s
+		eta  = "N/A"
+	)
+	if rate > 0 && input.Pos < input.Size {
+		eta = time.Duration(float64(input.Size-input.Pos) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	c.msg.Printf(
+		"progress: %s: %.1f%% (%s/%s) %s/s, eta: %s",
+		input.Path, done,
+		humanize(float64(input.Pos)), humanize(float64(input.Size)),
+		humanize(rate), eta,
+	)
+}
+
+// parseFDInfo parses the position and flags of a /proc/<pid>/fdinfo/<fd> file.
+func parseFDInfo(data []byte) (pos int64, flags uint64, err error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		switch k {
+		case "pos":
+			pos, err = strconv.ParseInt(v, 10, 64)
+		case "flags":
+			flags, err = strconv.ParseUint(v, 8, 64)
+		}
+		if err != nil {
+			return pos, flags, fmt.Errorf("could not parse %q: %w", k, err)
+		}
+	}
+	return pos, flags, nil
+}
+
+// accessMode returns the access mode ("r", "w" or "rw") of a file opened
+// with the provided flags.
+func accessMode(flags uint64) string {
+	switch flags & sys.O_ACCMODE {
+	case sys.O_RDONLY:
+		return "r"
+	case sys.O_WRONLY:
+		return "w"
+	default:
+		return "rw"
+	}
+}
+
+// humanize returns a human readable representation of a number of bytes.
+func humanize(v float64) string {
+	const unit = 1024
+	if v < unit {
+		return fmt.Sprintf("%.0fB", v)
+	}
+	var (
+		div = float64(unit)
+		exp = 0
+	)
+	for n := v / unit; n >= unit && exp < 4; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", v/div, "KMGTP"[exp])
+}
@@ -0,0 +1,64 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"testing"
+)
+
+func TestParseFDInfo(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		data  string
+		pos   int64
+		flags uint64
+		mode  string
+	}{
+		{
+			name:  "read-only",
+			data:  "pos:\t4096\nflags:\t0100000\nmnt_id:\t25\nino:\t3\n",
+			pos:   4096,
+			flags: 0100000,
+			mode:  "r",
+		},
+		{
+			name:  "write-only append",
+			data:  "pos:\t0\nflags:\t02102001\nmnt_id:\t25\nino:\t7\n",
+			pos:   0,
+			flags: 02102001,
+			mode:  "w",
+		},
+		{
+			name:  "read-write",
+			data:  "pos:\t123456789012\nflags:\t02\n",
+			pos:   123456789012,
+			flags: 02,
+			mode:  "rw",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pos, flags, err := parseFDInfo([]byte(tc.data))
+			if err != nil {
+				t.Fatalf("could not parse fdinfo: %+v", err)
+			}
+			if pos != tc.pos || flags != tc.flags {
+				t.Fatalf("invalid fdinfo: got=(%d, %o), want=(%d, %o)", pos, flags, tc.pos, tc.flags)
+			}
+			if got, want := accessMode(flags), tc.mode; got != want {
+				t.Fatalf("invalid access mode: got=%q, want=%q", got, want)
+			}
+		})
+	}
+
+	for _, data := range []string{
+		"pos:\t0\nflags:\t0x12\n",
+		"pos:\tx\nflags:\t0\n",
+	} {
+		_, _, err := parseFDInfo([]byte(data))
+		if err == nil {
+			t.Fatalf("expected an error parsing %q", data)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// psiResources are the resources for which pressure stall information
+// is available.
+var psiResources = []string{"cpu", "memory", "io"}
+
+// collectPSI collects the pressure stall information of the whole system,
+// from /proc/pressure, and of the cgroup v2 of the monitored process, when
+// available.
+func (c *collector) collectPSI() []PSIInfos {
+	var psi []PSIInfos
+	for _, res := range psiResources {
+		v, err := readPSI("/proc/pressure/" + res)
+		if err != nil {
+			continue
+		}
+		v.Source = "host"
+		v.Resource = res
+		psi = append(psi, v)
+	}
+
+	if c.cgroup.root == "" {
+		return psi
+	}
+	path, err := cgroupPath(c.pid)
+	if err != nil {
+		return psi
+	}
+	for _, res := range psiResources {
+		v, err := readPSI(filepath.Join(c.cgroup.root, path, res+".pressure"))
+		if err != nil {
+			continue
+		}
+		v.Source = "cgroup"
+		v.Resource = res
+		psi = append(psi, v)
+	}
+
+	return psi
+}
+
+// readPSI reads a pressure stall information file.
+func readPSI(fname string) (PSIInfos, error) {
+	var psi PSIInfos
+	raw, err := os.ReadFile(fname)
+	if err != nil {
+		return psi, err
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		// some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var (
+			avg10 *float64
+			total *time.Duration
+		)
+		switch fields[0] {
+		case "some":
+			avg10, total = &psi.SomeAvg10, &psi.SomeTotal
+		case "full":
+			avg10, total = &psi.FullAvg10, &psi.FullTotal
+		default:
+			continue
+		}
+		for _, field := range fields[1:] {
+			k, v, _ := strings.Cut(field, "=")
+			switch k {
+			case "avg10":
+				*avg10, err = strconv.ParseFloat(v, 64)
+			case "total":
+				var usec int64
+				usec, err = strconv.ParseInt(v, 10, 64)
+				*total = time.Duration(usec) * time.Microsecond
+			}
+			if err != nil {
+				return psi, fmt.Errorf("could not parse %s of %s: %w", k, fname, err)
+			}
+		}
+	}
+	return psi, nil
+}
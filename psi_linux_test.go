@@ -0,0 +1,69 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadPSI(t *testing.T) {
+	dir := t.TempDir()
+	read := func(data string) (PSIInfos, error) {
+		fname := filepath.Join(dir, "pressure")
+		err := os.WriteFile(fname, []byte(data), 0644)
+		if err != nil {
+			t.Fatalf("could not write fixture: %+v", err)
+		}
+		return readPSI(fname)
+	}
+
+	for _, tc := range []struct {
+		name string
+		data string
+		want PSIInfos
+	}{
+		{
+			name: "memory",
+			data: "some avg10=1.25 avg60=0.50 avg300=0.10 total=123456\nfull avg10=0.75 avg60=0.20 avg300=0.05 total=6543\n",
+			want: PSIInfos{
+				SomeAvg10: 1.25,
+				SomeTotal: 123456 * time.Microsecond,
+				FullAvg10: 0.75,
+				FullTotal: 6543 * time.Microsecond,
+			},
+		},
+		{
+			name: "cpu without full line",
+			data: "some avg10=0.00 avg60=0.00 avg300=0.00 total=42\n",
+			want: PSIInfos{SomeTotal: 42 * time.Microsecond},
+		},
+	} {
+		got, err := read(tc.data)
+		if err != nil {
+			t.Fatalf("could not read psi of %s: %+v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("invalid psi of %s:\ngot= %+v\nwant=%+v", tc.name, got, tc.want)
+		}
+	}
+
+	for _, data := range []string{
+		"some avg10=x avg60=0.00 avg300=0.00 total=42\n",
+		"full avg10=0.00 avg60=0.00 avg300=0.00 total=-\n",
+	} {
+		_, err := read(data)
+		if err == nil {
+			t.Fatalf("expected an error reading %q", data)
+		}
+	}
+
+	_, err := readPSI(filepath.Join(dir, "missing"))
+	if err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+}
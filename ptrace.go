@@ -6,7 +6,6 @@ package pmon
 
 import (
 	"runtime"
-	"syscall"
 )
 
 // ptraceRun runs all the closures from fc on a dedicated OS thread. Errors
@@ -22,13 +21,6 @@ func ptraceRun(fc chan func() error, ec chan error) {
 	}
 }
 
-func (p *Process) ptraceDetach(pid int) error {
-	p.fc <- func() error {
-		return syscall.PtraceDetach(pid)
-	}
-	return <-p.ec
-}
-
 func (p *Process) ptraceRun(f func() error) error {
 	p.fc <- f
 	return <-p.ec
@@ -0,0 +1,59 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// errNoMatch is returned when no process matches the selector of the
+// monitoring.
+var errNoMatch = errors.New("no process matching the selector")
+
+// Selector selects the processes to monitor by their attributes, in the
+// fashion of pgrep.
+// A process is selected if it matches all the criteria that are set.
+// The pmon process itself is never selected.
+type Selector struct {
+	Comm    string         // exact command name, as in /proc/<pid>/comm
+	Cmdline *regexp.Regexp // pattern matched against the full command line
+	UID     int            // real user id, or -1 for any user
+	PGID    int            // process group id, or 0 for any group
+	SID     int            // session id, or 0 for any session
+}
+
+// NewSelector returns a selector matching any process.
+func NewSelector() Selector {
+	return Selector{UID: -1}
+}
+
+// String returns the criteria of the selector, as a space separated list
+// of key=value pairs.
+func (sel Selector) String() string {
+	var o []string
+	if sel.Comm != "" {
+		o = append(o, fmt.Sprintf("comm=%q", sel.Comm))
+	}
+	if sel.Cmdline != nil {
+		o = append(o, fmt.Sprintf("cmdline=%q", sel.Cmdline))
+	}
+	if sel.UID >= 0 {
+		o = append(o, fmt.Sprintf("uid=%d", sel.UID))
+	}
+	if sel.PGID > 0 {
+		o = append(o, fmt.Sprintf("pgid=%d", sel.PGID))
+	}
+	if sel.SID > 0 {
+		o = append(o, fmt.Sprintf("sid=%d", sel.SID))
+	}
+	return strings.Join(o, " ")
+}
+
+func (sel Selector) empty() bool {
+	return sel.String() == ""
+}
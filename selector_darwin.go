@@ -0,0 +1,11 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import "fmt"
+
+func selectProcs(sel *Selector) ([]int, error) {
+	return nil, fmt.Errorf("process selection not supported on darwin")
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// match returns whether the provided process matches the selector.
+// The criteria available in /proc/<pid>/stat are checked first, so that
+// /proc/<pid>/status and /proc/<pid>/cmdline are only read for candidates.
+func (sel *Selector) match(pid int, stat procStat) bool {
+	switch {
+	case pid == os.Getpid():
+		return false
+	case sel.Comm != "" && stat.comm != sel.Comm:
+		return false
+	case sel.PGID > 0 && stat.pgrp != sel.PGID:
+		return false
+	case sel.SID > 0 && stat.session != sel.SID:
+		return false
+	}
+
+	if sel.UID >= 0 {
+		raw, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/status")
+		if err != nil {
+			return false
+		}
+		if uid, _ := readIDs(raw, "Uid"); uid != sel.UID {
+			return false
+		}
+	}
+
+	if sel.Cmdline != nil {
+		argv, err := readCmdline(pid)
+		if err != nil {
+			return false
+		}
+		cmdline := strings.Join(argv, " ")
+		if cmdline == "" {
+			// kernel thread: match its name, like pgrep -f.
+			cmdline = stat.comm
+		}
+		if !sel.Cmdline.MatchString(cmdline) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// selectProcs returns the sorted list of the running processes matching the
+// provided selector.
+func selectProcs(sel *Selector) ([]int, error) {
+	stats, err := scanProcs()
+	if err != nil {
+		return nil, fmt.Errorf("could not scan processes: %w", err)
+	}
+
+	var pids []int
+	for pid, stat := range stats {
+		if sel.match(pid, stat) {
+			pids = append(pids, pid)
+		}
+	}
+	slices.Sort(pids)
+	return pids, nil
+}
@@ -0,0 +1,393 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Session monitors the resources usage of many independent processes at
+// once, commands created by New as well as running processes monitored by
+// Monitor or Select.
+//
+// The processes of a session are sampled on a shared ticker by a fixed pool
+// of workers, instead of one ticker and goroutine per process.
+// At each tick, the running processes are scanned once for all the process
+// trees and selections of the session, and the exits of its processes are
+// checked.
+// Their samples are written either to their own W, or to the multiplexed
+// log W of the session, each line being prefixed with "@<name> ".
+// ParseSession splits a multiplexed log back into its processes.
+type Session struct {
+	// W is the multiplexed log of the session.
+	// If W is nil, each process writes to its own W.
+	W io.Writer
+
+	// Freq is the sampling period of all the processes of the session.
+	Freq time.Duration
+
+	// Workers is the number of processes sampled concurrently.
+	// A non-positive value uses the number of CPUs.
+	Workers int
+
+	Msg *log.Logger
+
+	names []string
+	procs []*Process
+
+	wmu sync.Mutex // serializes writes to W
+
+	mu      sync.Mutex
+	jobs    []*sessionJob    // processes currently sampled
+	watches []*exitWatch     // processes whose exit is awaited
+	work    chan *sessionJob // samplings to perform
+	done    chan struct{}    // closed once all the processes have ended
+}
+
+// sessionJob is the sampling of a process of a session.
+type sessionJob struct {
+	p    *Process
+	c    *collector
+	busy atomic.Bool // whether a sampling of the process is in progress
+}
+
+// exitWatch is the watch of the exit of a process of a session.
+type exitWatch struct {
+	pid  int
+	quit <-chan struct{} // closed when the monitoring of the process ends
+	done chan struct{}   // closed when the process exits
+}
+
+// NewSession creates a new, empty, monitoring session.
+func NewSession() *Session {
+	return &Session{
+		Freq: 1 * time.Second,
+		Msg:  log.Default(),
+	}
+}
+
+// Add adds the provided process to the session, under the provided name.
+// The name identifies the process in the multiplexed log and in the
+// messages of the session: it must be unique and may not contain spaces.
+func (s *Session) Add(name string, p *Process) error {
+	switch {
+	case name == "" || strings.ContainsAny(name, " \t\r\n"):
+		return fmt.Errorf("invalid process name %q", name)
+	case p.session != nil:
+		return fmt.Errorf("process %q already belongs to a session", name)
+	}
+	for _, v := range s.names {
+		if v == name {
+			return fmt.Errorf("duplicate process name %q", name)
+		}
+	}
+
+	p.session = s
+	s.names = append(s.names, name)
+	s.procs = append(s.procs, p)
+	return nil
+}
+
+// Run starts the monitoring of all the processes of the session, and waits
+// for all of them to end.
+// Run sets the sampling period and the logger of each process, and their
+// writer when the session has a multiplexed log.
+func (s *Session) Run() error {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	s.work = make(chan *sessionJob)
+	s.done = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.worker()
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.tick()
+	}()
+
+	errs := make([]error, len(s.procs))
+	var procs sync.WaitGroup
+	for i, p := range s.procs {
+		name := s.names[i]
+		p.Freq = s.Freq
+		p.Msg = log.New(s.Msg.Writer(), s.Msg.Prefix()+name+": ", s.Msg.Flags())
+		if s.W != nil {
+			p.W = &muxWriter{s: s, prefix: []byte("@" + name + " ")}
+		}
+
+		procs.Add(1)
+		go func() {
+			defer procs.Done()
+			err := p.Run()
+			if err != nil {
+				errs[i] = fmt.Errorf("could not monitor %q: %w", name, err)
+			}
+		}()
+	}
+	procs.Wait()
+
+	close(s.done)
+	wg.Wait()
+
+	if w, ok := s.W.(interface{ Flush() error }); ok {
+		s.wmu.Lock()
+		err := w.Flush()
+		s.wmu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not flush session log: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Kill kills the commands of the session, and ends the monitoring of its
+// running processes.
+func (s *Session) Kill() error {
+	var errs []error
+	for i, p := range s.procs {
+		err := p.Kill()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not kill %q: %w", s.names[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// schedule adds the sampling of the provided process to the shared ticker
+// of the session, and samples it right away.
+func (s *Session) schedule(p *Process, c *collector) {
+	job := &sessionJob{p: p, c: c}
+	job.busy.Store(true)
+
+	s.mu.Lock()
+	s.jobs = append(s.jobs, job)
+	s.mu.Unlock()
+
+	select {
+	case s.work <- job:
+	case <-s.done:
+	}
+}
+
+// watch returns a channel closed when the process with the provided pid
+// exits, or when quit is closed.
+// The processes are checked at each tick of the session, from the shared
+// snapshot of the running processes.
+func (s *Session) watch(pid int, quit <-chan struct{}) <-chan struct{} {
+	w := &exitWatch{pid: pid, quit: quit, done: make(chan struct{})}
+
+	s.mu.Lock()
+	s.watches = append(s.watches, w)
+	s.mu.Unlock()
+
+	return w.done
+}
+
+// worker samples the processes dispatched by tick, until the session ends.
+func (s *Session) worker() {
+	for {
+		select {
+		case job := <-s.work:
+			select {
+			case <-job.p.quit:
+				// monitoring of the process already ended.
+			default:
+				job.p.collect(job.c)
+			}
+			job.busy.Store(false)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// tick dispatches the sampling of the processes of the session to the
+// workers, at each tick, until the session ends.
+// A process whose previous sampling is still in progress is skipped.
+func (s *Session) tick() {
+	tick := time.NewTicker(s.Freq)
+	defer tick.Stop()
+
+	var jobs []*sessionJob
+	for {
+		select {
+		case <-tick.C:
+		case <-s.done:
+			return
+		}
+
+		// the running processes are scanned once for all the processes
+		// of the session.
+		table := scanTable()
+
+		jobs = jobs[:0]
+		s.mu.Lock()
+		s.watches = slices.DeleteFunc(s.watches, func(w *exitWatch) bool {
+			select {
+			case <-w.quit:
+			default:
+				if table.alive(w.pid) {
+					return false
+				}
+			}
+			close(w.done)
+			return true
+		})
+		s.jobs = slices.DeleteFunc(s.jobs, func(job *sessionJob) bool {
+			select {
+			case <-job.p.quit:
+				return true
+			default:
+				return false
+			}
+		})
+		for _, job := range s.jobs {
+			if job.busy.CompareAndSwap(false, true) {
+				jobs = append(jobs, job)
+			}
+		}
+		s.mu.Unlock()
+
+		for _, job := range jobs {
+			job.c.share(table)
+			select {
+			case s.work <- job:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+// muxWriter writes the log of a process of a session to the multiplexed
+// log of the session, one complete line at a time, prefixed with the name
+// of the process.
+type muxWriter struct {
+	s      *Session
+	prefix []byte
+	buf    []byte // incomplete last line
+}
+
+func (w *muxWriter) Write(p []byte) (int, error) {
+	w.s.wmu.Lock()
+	defer w.s.wmu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	defer func() {
+		// keep the incomplete last line for the next write.
+		i := bytes.LastIndexByte(w.buf, '\n')
+		w.buf = w.buf[:copy(w.buf, w.buf[i+1:])]
+	}()
+
+	lines := w.buf
+	for {
+		i := bytes.IndexByte(lines, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		err := w.write(lines[:i+1])
+		if err != nil {
+			return len(p), err
+		}
+		lines = lines[i+1:]
+	}
+}
+
+// Flush writes the incomplete last line of the process, if any, and
+// flushes the multiplexed log.
+func (w *muxWriter) Flush() error {
+	w.s.wmu.Lock()
+	defer w.s.wmu.Unlock()
+
+	if len(w.buf) > 0 {
+		err := w.write(append(w.buf, '\n'))
+		w.buf = nil
+		if err != nil {
+			return err
+		}
+	}
+	if f, ok := w.s.W.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (w *muxWriter) write(line []byte) error {
+	_, err := w.s.W.Write(w.prefix)
+	if err != nil {
+		return err
+	}
+	_, err = w.s.W.Write(line)
+	return err
+}
+
+// ParseSession parses the multiplexed log of a session, and returns the
+// monitoring data of each of its processes, indexed by name.
+// Malformed lines and logs are reported in the returned error, alongside
+// the monitoring data that could be parsed.
+func ParseSession(r io.Reader) (map[string]Meta, error) {
+	var (
+		logs  = make(map[string]*bytes.Buffer)
+		names []string
+		err   error
+	)
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		txt := sc.Text()
+		if txt == "" {
+			continue
+		}
+		name, line, ok := strings.Cut(txt, " ")
+		if !ok || len(name) < 2 || name[0] != '@' {
+			err = errors.Join(err, fmt.Errorf("invalid session log line %q", txt))
+			continue
+		}
+		name = name[1:]
+		buf, ok := logs[name]
+		if !ok {
+			buf = new(bytes.Buffer)
+			logs[name] = buf
+			names = append(names, name)
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	if e := sc.Err(); e != nil {
+		err = errors.Join(err, fmt.Errorf("could not scan session log: %w", e))
+	}
+
+	metas := make(map[string]Meta, len(names))
+	for _, name := range names {
+		meta, e := Parse(logs[name])
+		if e != nil {
+			err = errors.Join(err, fmt.Errorf("could not parse log of %q: %w", name, e))
+		}
+		metas[name] = meta
+	}
+	return metas, err
+}
@@ -0,0 +1,21 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"errors"
+	"syscall"
+)
+
+// procTable is not implemented on darwin: processes are probed one by one.
+type procTable struct{}
+
+func scanTable() procTable { return procTable{} }
+
+func (procTable) alive(pid int) bool {
+	return !errors.Is(syscall.Kill(pid, 0), syscall.ESRCH)
+}
+
+func (c *collector) share(t procTable) {}
@@ -0,0 +1,35 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+// procTable is a snapshot of the running processes, scanned once per tick
+// by a session and shared by all its processes.
+type procTable map[int]procStat
+
+// scanTable returns a snapshot of the running processes, or nil if /proc
+// could not be scanned.
+func scanTable() procTable {
+	stats, err := scanProcs()
+	if err != nil {
+		return nil
+	}
+	return stats
+}
+
+// alive returns whether the provided process is running.
+// All processes are considered running in an empty snapshot.
+func (t procTable) alive(pid int) bool {
+	if t == nil {
+		return true
+	}
+	stat, ok := t[pid]
+	return ok && stat.state != 'Z'
+}
+
+// share provides the snapshot of the running processes to use for the next
+// collection, instead of scanning /proc again.
+func (c *collector) share(t procTable) {
+	c.table = t
+}
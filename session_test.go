@@ -0,0 +1,113 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSession(t *testing.T) {
+	var (
+		buf = new(bytes.Buffer)
+		s   = &Session{W: bufio.NewWriter(buf)}
+		a   = &muxWriter{s: s, prefix: []byte("@a ")}
+		b   = &muxWriter{s: s, prefix: []byte("@b ")}
+	)
+
+	// lines of the processes are interleaved, and written in pieces.
+	fmt.Fprintf(a, "# pmon: sleep 1\n# freq: 1s\n")
+	fmt.Fprintf(b, "# pmon: sleep 2\n# fr")
+	fmt.Fprintf(a, infosFmt+"\n", (&Infos{CPU: 10 * time.Millisecond, RSS: 1024}).args()...)
+	fmt.Fprintf(b, "eq: 2s\n")
+	fmt.Fprintf(b, infosFmt, (&Infos{CPU: 20 * time.Millisecond, RSS: 2048}).args()...)
+	fmt.Fprintf(a, "fds: 3 0 0 0 0 0 3 3 1024\n")
+	for _, w := range []*muxWriter{a, b} {
+		err := w.Flush()
+		if err != nil {
+			t.Fatalf("could not flush: %+v", err)
+		}
+	}
+
+	metas, err := ParseSession(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("could not parse session log:\n%s\nerror: %+v", buf.Bytes(), err)
+	}
+	if got, want := len(metas), 2; got != want {
+		t.Fatalf("invalid number of processes: got=%d, want=%d", got, want)
+	}
+	for _, tc := range []struct {
+		name string
+		cmd  string
+		freq time.Duration
+		rss  int64
+		fds  int
+	}{
+		{"a", "sleep 1", 1 * time.Second, 1024, 1},
+		{"b", "sleep 2", 2 * time.Second, 2048, 0},
+	} {
+		meta, ok := metas[tc.name]
+		if !ok {
+			t.Fatalf("missing log of %q", tc.name)
+		}
+		if meta.Cmd != tc.cmd || meta.Freq != tc.freq {
+			t.Errorf("invalid header of %q: cmd=%q freq=%v", tc.name, meta.Cmd, meta.Freq)
+		}
+		if len(meta.Infos) != 1 || meta.Infos[0].RSS != tc.rss {
+			t.Errorf("invalid samples of %q: %+v", tc.name, meta.Infos)
+		}
+		if len(meta.FDs) != tc.fds {
+			t.Errorf("invalid fds of %q: %+v", tc.name, meta.FDs)
+		}
+	}
+}
+
+func TestParseSessionPartial(t *testing.T) {
+	const log = `@a # pmon: sleep 1
+@b # pmon: sleep 2
+no prefix
+@ empty name
+@a 10.000000 10.000000 0.000000 2048 1024 1 0 0 0 0
+@b 10.000000 x
+@b 20.000000 20.000000 0.000000 2048 1024 1 0 0 0 0
+`
+	metas, err := ParseSession(strings.NewReader(log))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	for _, want := range []string{
+		`invalid session log line "no prefix"`,
+		`invalid session log line "@ empty name"`,
+		`could not parse log of "b"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in error: %+v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), `log of "a"`) {
+		t.Errorf("unexpected error for a valid log: %+v", err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		cmd   string
+		infos int
+	}{
+		{"a", "sleep 1", 1},
+		{"b", "sleep 2", 1},
+	} {
+		meta, ok := metas[tc.name]
+		if !ok {
+			t.Fatalf("missing partial log of %q", tc.name)
+		}
+		if meta.Cmd != tc.cmd || len(meta.Infos) != tc.infos {
+			t.Errorf("invalid partial log of %q: cmd=%q infos=%d", tc.name, meta.Cmd, len(meta.Infos))
+		}
+	}
+}
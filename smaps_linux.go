@@ -0,0 +1,140 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"bufio"
+	"cmp"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// collectSmaps collects the proportional and unique memory usage of the
+// monitored processes, from /proc/<pid>/smaps_rollup.
+func (c *collector) collectSmaps() *SmapsInfos {
+	var smaps SmapsInfos
+	for _, pid := range c.pids() {
+		raw, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/smaps_rollup")
+		if err != nil {
+			c.msg.Printf("could not read smaps_rollup of pid=%d: %+v", pid, err)
+			continue
+		}
+		var pss, pclean, pdirty, swap, swappss int64
+		err = scanKV(raw, map[string]*int64{
+			"Pss":           &pss,
+			"Private_Clean": &pclean,
+			"Private_Dirty": &pdirty,
+			"Swap":          &swap,
+			"SwapPss":       &swappss,
+		})
+		if err != nil {
+			c.msg.Printf("could not parse smaps_rollup of pid=%d: %+v", pid, err)
+			continue
+		}
+		smaps.PSS += pss
+		smaps.USS += pclean + pdirty
+		smaps.Swap += swap
+		smaps.SwapPSS += swappss
+	}
+	return &smaps
+}
+
+// collectMaps collects the memory usage of the monitored processes from
+// /proc/<pid>/smaps, grouped by mapping.
+// Only the c.maps.top largest groups (by RSS) are returned.
+func (c *collector) collectMaps() []MapsInfos {
+	groups := make(map[string]*MapsInfos)
+	for _, pid := range c.pids() {
+		err := c.scanMaps(pid, groups)
+		if err != nil {
+			c.msg.Printf("could not read smaps of pid=%d: %+v", pid, err)
+			continue
+		}
+	}
+
+	maps := make([]MapsInfos, 0, len(groups))
+	for _, v := range groups {
+		maps = append(maps, *v)
+	}
+	slices.SortFunc(maps, func(a, b MapsInfos) int {
+		if o := cmp.Compare(b.RSS, a.RSS); o != 0 {
+			return o
+		}
+		return cmp.Compare(a.Path, b.Path)
+	})
+	if len(maps) > c.maps.top {
+		maps = maps[:c.maps.top]
+	}
+	return maps
+}
+
+// scanMaps scans /proc/<pid>/smaps and accumulates the memory usage of each
+// mapping into groups, indexed by mapping path.
+func (c *collector) scanMaps(pid int, groups map[string]*MapsInfos) error {
+	f, err := os.Open("/proc/" + strconv.Itoa(pid) + "/smaps")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var (
+		cur *MapsInfos
+		sc  = bufio.NewScanner(f)
+	)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		key, ok := strings.CutSuffix(fields[0], ":")
+		if !ok {
+			// mapping header: address perms offset dev inode [path]
+			path := mapsPath(fields)
+			cur = groups[path]
+			if cur == nil {
+				cur = &MapsInfos{Path: path}
+				groups[path] = cur
+			}
+			continue
+		}
+		if cur == nil || len(fields) < 2 {
+			continue
+		}
+
+		var dst *int64
+		switch key {
+		case "Rss":
+			dst = &cur.RSS
+		case "Pss":
+			dst = &cur.PSS
+		case "Private_Clean", "Private_Dirty":
+			dst = &cur.Private
+		case "AnonHugePages":
+			dst = &cur.AnonHuge
+		default:
+			continue
+		}
+		v, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		*dst += v
+	}
+
+	return sc.Err()
+}
+
+// mapsPath returns the name of the group a mapping belongs to, given the
+// fields of its smaps header line.
+// Anonymous mappings are all grouped under "[anon]".
+func mapsPath(fields []string) string {
+	const npre = 5 // address perms offset dev inode
+	if len(fields) <= npre {
+		return "[anon]"
+	}
+	return strings.Join(fields[npre:], " ")
+}
@@ -0,0 +1,19 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import "fmt"
+
+func setSubreaper() error {
+	return fmt.Errorf("child subreaper not supported on darwin")
+}
+
+type orphans struct{}
+
+func newOrphans() *orphans        { return &orphans{} }
+func (o *orphans) track(root int) {}
+func (o *orphans) adopted() []int { return nil }
+func (o *orphans) reap() []int    { return nil }
+func comm(pid int) string         { return "<N/A>" }
@@ -0,0 +1,131 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"sync"
+
+	sys "golang.org/x/sys/unix"
+)
+
+// setSubreaper marks the current process as a child subreaper: orphaned
+// descendants are reparented to it, instead of init.
+func setSubreaper() error {
+	return sys.Prctl(sys.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0)
+}
+
+// orphans tracks the descendants of a command, to recognize the ones
+// adopted by the current process, as a child subreaper, among all its
+// children (e.g. the other commands of a Session).
+// A process is adopted if its parent has become the current process, and
+// it was seen in the process tree of the command or it still belongs to the
+// process group of the command (e.g. orphaned before it could be seen.)
+type orphans struct {
+	mu   sync.Mutex
+	root int           // pid of the command, once started
+	seen map[int]int64 // start time of the descendants seen in the tree, by pid
+}
+
+func newOrphans() *orphans {
+	return &orphans{seen: make(map[int]int64)}
+}
+
+// track starts the tracking of the descendants of the provided command.
+func (o *orphans) track(root int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.root = root
+}
+
+// update records the descendants of the command found in the provided
+// process table, and returns the adopted ones, sorted by pid.
+func (o *orphans) update(stats map[int]procStat) []int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.root <= 0 {
+		return nil
+	}
+
+	for pid, start := range o.seen {
+		if stat, ok := stats[pid]; !ok || stat.starttime != start {
+			// process exited (and its pid may have been recycled.)
+			delete(o.seen, pid)
+		}
+	}
+
+	// add descendants until the tree does not grow anymore.
+	for {
+		n := len(o.seen)
+		for pid, stat := range stats {
+			if _, ok := o.seen[pid]; ok || pid == o.root {
+				continue
+			}
+			if _, ok := o.seen[stat.ppid]; ok || stat.ppid == o.root {
+				o.seen[pid] = stat.starttime
+			}
+		}
+		if len(o.seen) == n {
+			break
+		}
+	}
+
+	self := os.Getpid()
+	var pids []int
+	for pid, stat := range stats {
+		if stat.ppid != self || pid == o.root {
+			continue
+		}
+		_, seen := o.seen[pid]
+		if seen || stat.pgrp == o.root {
+			// commands are started in their own process group.
+			o.seen[pid] = stat.starttime
+			pids = append(pids, pid)
+		}
+	}
+	slices.Sort(pids)
+	return pids
+}
+
+// adopted returns the descendants of the command adopted by the current
+// process, sorted by pid.
+func (o *orphans) adopted() []int {
+	stats, err := scanProcs()
+	if err != nil {
+		return nil
+	}
+	return o.update(stats)
+}
+
+// reap reaps the exited descendants adopted by the current process, and
+// returns the live ones.
+func (o *orphans) reap() []int {
+	var live []int
+	for _, pid := range o.adopted() {
+		var ws sys.WaitStatus
+		wpid, err := sys.Wait4(pid, &ws, sys.WNOHANG, nil)
+		switch {
+		case errors.Is(err, sys.ECHILD):
+			// already reaped.
+		case err == nil && wpid == pid:
+			// reaped.
+		default:
+			live = append(live, pid)
+		}
+	}
+	return live
+}
+
+// comm returns the command name of the provided process.
+func comm(pid int) string {
+	stat, err := readStat(pid)
+	if err != nil {
+		return "<N/A>"
+	}
+	return stat.comm
+}
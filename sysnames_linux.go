@@ -0,0 +1,19 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import "strconv"
+
+//go:generate go run ./internal/mksysnames -arch amd64 /usr/include/x86_64-linux-gnu/asm/unistd_64.h
+//go:generate go run ./internal/mksysnames -arch arm64 /usr/include/asm-generic/unistd.h
+
+// syscallName returns the name of the syscall with the provided number.
+// Syscalls with no known name are named after their number.
+func syscallName(nr int) string {
+	if 0 <= nr && nr < len(sysnames) && sysnames[nr] != "" {
+		return sysnames[nr]
+	}
+	return "sys_" + strconv.Itoa(nr)
+}
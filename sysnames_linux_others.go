@@ -0,0 +1,11 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux && !amd64 && !arm64
+
+package pmon
+
+// sysnames is empty on architectures without a generated syscall names
+// table: syscalls are named after their number.
+var sysnames = [...]string{}
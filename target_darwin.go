@@ -0,0 +1,13 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import "fmt"
+
+// readTarget is not implemented on darwin.
+func readTarget(pid int) (TargetInfos, error) {
+	target := TargetInfos{PID: pid, UID: -1, EUID: -1, GID: -1, EGID: -1}
+	return target, fmt.Errorf("process metadata not supported on darwin")
+}
@@ -0,0 +1,118 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readTarget reads the metadata of the provided process from /proc/<pid>.
+// Metadata that can not be read (e.g. exe or cwd of a process owned by
+// another user) are left empty.
+func readTarget(pid int) (TargetInfos, error) {
+	var (
+		dir    = "/proc/" + strconv.Itoa(pid)
+		target = TargetInfos{PID: pid, UID: -1, EUID: -1, GID: -1, EGID: -1}
+	)
+
+	argv, err := readCmdline(pid)
+	if err != nil {
+		return target, fmt.Errorf("could not read command line of pid=%d: %w", pid, err)
+	}
+	target.Argv = argv
+	target.Exe, _ = os.Readlink(dir + "/exe")
+	target.Cwd, _ = os.Readlink(dir + "/cwd")
+
+	if raw, err := os.ReadFile(dir + "/status"); err == nil {
+		target.UID, target.EUID = readIDs(raw, "Uid")
+		target.GID, target.EGID = readIDs(raw, "Gid")
+	}
+
+	if stat, err := readStat(pid); err == nil {
+		btime, err := readBootTime()
+		if err == nil {
+			since := time.Duration(uint64(stat.starttime) * clockTicksToNanosecond)
+			target.Started = btime.Add(since)
+		}
+	}
+
+	target.Cgroup, _ = cgroupPath(pid)
+
+	if ents, err := os.ReadDir(dir + "/ns"); err == nil {
+		for _, ent := range ents {
+			link, err := os.Readlink(dir + "/ns/" + ent.Name())
+			if err != nil {
+				continue
+			}
+			// name the namespace after its entry, to distinguish
+			// pid and pid_for_children.
+			_, inode, _ := strings.Cut(link, ":")
+			target.Namespaces = append(target.Namespaces, ent.Name()+":"+inode)
+		}
+		slices.Sort(target.Namespaces)
+	}
+
+	return target, nil
+}
+
+// readCmdline returns the command line arguments of the provided process.
+func readCmdline(pid int) ([]string, error) {
+	raw, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/cmdline")
+	if err != nil {
+		return nil, err
+	}
+	raw = []byte(strings.TrimRight(string(raw), "\x00"))
+	if len(raw) == 0 {
+		// kernel thread, or zombie process.
+		return nil, nil
+	}
+	return strings.Split(string(raw), "\x00"), nil
+}
+
+// readIDs returns the real and effective ids of the provided /proc/<pid>/status
+// line ("Uid" or "Gid").
+func readIDs(status []byte, key string) (id, eid int) {
+	id, eid = -1, -1
+	for _, line := range strings.Split(string(status), "\n") {
+		v, ok := strings.CutPrefix(line, key+":")
+		if !ok {
+			continue
+		}
+		// real effective saved filesystem
+		fields := strings.Fields(v)
+		if len(fields) < 2 {
+			break
+		}
+		id, _ = strconv.Atoi(fields[0])
+		eid, _ = strconv.Atoi(fields[1])
+		break
+	}
+	return id, eid
+}
+
+// readBootTime returns the boot time of the system, from /proc/stat.
+func readBootTime() (time.Time, error) {
+	raw, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		v, ok := strings.CutPrefix(line, "btime ")
+		if !ok {
+			continue
+		}
+		btime, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("could not parse boot time: %w", err)
+		}
+		return time.Unix(btime, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("could not find boot time")
+}
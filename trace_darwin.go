@@ -0,0 +1,35 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// tracer is not implemented on darwin.
+type tracer struct {
+	exited atomic.Bool
+	status syscall.WaitStatus
+	rusage syscall.Rusage
+}
+
+func newTracer(pid int, start time.Time, syscalls, lifecycle bool) *tracer {
+	return &tracer{}
+}
+
+func (t *tracer) attach() error {
+	return fmt.Errorf("syscall tracing not supported on darwin")
+}
+
+func (t *tracer) run() error              { return nil }
+func (t *tracer) interval() []StraceInfos { return nil }
+func (t *tracer) events() []EventInfos    { return nil }
+func (t *tracer) summary(msg *log.Logger) {}
+func (t *tracer) leftovers() []int        { return nil }
+func (t *tracer) detach()                 {}
@@ -0,0 +1,441 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	sys "golang.org/x/sys/unix"
+)
+
+// tracer follows the monitored command and all its descendants with ptrace,
+// optionally stopping them at each syscall entry and exit, and recording
+// their lifecycle events (fork, exec, exit and signals).
+//
+// The tracer reaps all the tasks it traces, including the monitored command:
+// the exit status and resources usage of the latter are recorded by the
+// tracer, as Cmd.Wait can not be used anymore.
+//
+// All the methods of tracer, except interval, events, summary, leftovers and
+// detach, must be called from the ptrace thread.
+type tracer struct {
+	pid       int             // pid of the monitored command
+	start     time.Time       // start of the monitoring
+	syscalls  bool            // whether to trace syscalls
+	lifecycle bool            // whether to record lifecycle events
+	tasks     map[int]*tracee // traced tasks, indexed by tid
+
+	exited    atomic.Bool        // whether the monitored command has exited
+	detaching atomic.Bool        // whether to detach from the traced tasks
+	status    syscall.WaitStatus // exit status of the monitored command
+	rusage    syscall.Rusage     // resources usage of the monitored command
+
+	mu    sync.Mutex           // guards tasks insertions and deletions, and the statistics
+	total map[int]*syscallStat // syscall statistics, indexed by syscall number
+	delta map[int]*syscallStat // syscall statistics since the last interval
+	evts  []EventInfos         // lifecycle events since the last call to events
+}
+
+// tracee holds the syscall state of a traced task.
+type tracee struct {
+	started bool      // whether the task has been stopped once already
+	insys   bool      // whether the task is between a syscall entry and exit
+	nr      int       // number of the current syscall
+	entry   time.Time // time of the current syscall entry
+}
+
+// syscallStat holds the statistics of a syscall.
+type syscallStat struct {
+	calls  int64
+	errors int64
+	time   time.Duration
+}
+
+func newTracer(pid int, start time.Time, syscalls, lifecycle bool) *tracer {
+	return &tracer{
+		pid:       pid,
+		start:     start,
+		syscalls:  syscalls,
+		lifecycle: lifecycle,
+		tasks:     map[int]*tracee{pid: {started: true}},
+		total:     make(map[int]*syscallStat),
+		delta:     make(map[int]*syscallStat),
+	}
+}
+
+// attach configures the tracing of the monitored command, stopped after its
+// execve, and resumes it.
+func (t *tracer) attach() error {
+	if t.syscalls && !syscallRegsSupported {
+		return fmt.Errorf("syscall tracing not supported on this architecture")
+	}
+	err := sys.PtraceSetOptions(t.pid,
+		sys.PTRACE_O_TRACESYSGOOD|
+			sys.PTRACE_O_TRACEFORK|
+			sys.PTRACE_O_TRACEVFORK|
+			sys.PTRACE_O_TRACECLONE|
+			sys.PTRACE_O_TRACEEXEC,
+	)
+	if err != nil {
+		return fmt.Errorf("could not set ptrace options of pid=%d: %w", t.pid, err)
+	}
+	return t.resume(t.pid, 0)
+}
+
+// resume resumes a stopped traced task, delivering the provided signal.
+func (t *tracer) resume(tid int, sig sys.Signal) error {
+	if t.syscalls {
+		return sys.PtraceSyscall(tid, int(sig))
+	}
+	return sys.PtraceCont(tid, int(sig))
+}
+
+// run handles the ptrace stops of the traced tasks, until all of them have
+// exited.
+func (t *tracer) run() error {
+	for len(t.tasks) > 0 {
+		var (
+			ws syscall.WaitStatus
+			ru syscall.Rusage
+		)
+		// only wait for the children of the ptrace thread, so that the
+		// commands started by other threads (e.g. in a Session) are left
+		// to their own Cmd.Wait.
+		tid, err := syscall.Wait4(-1, &ws, sys.WALL|sys.WNOTHREAD, &ru)
+		switch {
+		case errors.Is(err, sys.EINTR):
+			continue
+		case errors.Is(err, sys.ECHILD):
+			return nil
+		case err != nil:
+			return fmt.Errorf("could not wait for traced tasks: %w", err)
+		}
+		now := time.Now()
+
+		if ws.Exited() || ws.Signaled() {
+			if _, ok := t.tasks[tid]; !ok {
+				// not a traced task.
+				continue
+			}
+			t.exit(tid, ws, ru, now)
+			continue
+		}
+		if !ws.Stopped() {
+			continue
+		}
+
+		// only traced tasks report stops without WUNTRACED, and new
+		// children may be reported before the fork/clone event of their
+		// parent.
+		task := t.task(tid)
+		started := task.started
+		task.started = true
+
+		if t.detaching.Load() {
+			t.release(tid, ws, started)
+			continue
+		}
+
+		sig := ws.StopSignal()
+		switch {
+		case sig == sys.SIGTRAP|0x80:
+			t.syscall(tid, task, now)
+			sig = 0
+
+		case sig == sys.SIGTRAP && ws.TrapCause() > 0:
+			switch cause := ws.TrapCause(); cause {
+			case sys.PTRACE_EVENT_FORK, sys.PTRACE_EVENT_VFORK, sys.PTRACE_EVENT_CLONE:
+				msg, err := sys.PtraceGetEventMsg(tid)
+				if err != nil {
+					break
+				}
+				child := int(msg)
+				t.task(child)
+				t.event(now, forkEvents[cause], child, tid, "")
+			case sys.PTRACE_EVENT_EXEC:
+				t.event(now, "exec", tid, 0, readArgv(tid))
+			}
+			sig = 0
+
+		case sig == sys.SIGSTOP && !started:
+			// initial stop of a new child.
+			sig = 0
+
+		case isGroupStop(tid):
+			sig = 0
+
+		default:
+			t.event(now, "signal", tid, int(sig), sys.SignalName(sig))
+		}
+
+		err = t.resume(tid, sig)
+		if err != nil && !errors.Is(err, sys.ESRCH) {
+			return fmt.Errorf("could not resume traced task tid=%d: %w", tid, err)
+		}
+	}
+	return nil
+}
+
+// task returns the state of the provided traced task, tracking it if needed.
+func (t *tracer) task(tid int) *tracee {
+	task, ok := t.tasks[tid]
+	if !ok {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		task = new(tracee)
+		t.tasks[tid] = task
+	}
+	return task
+}
+
+// exit handles the exit of a traced task.
+func (t *tracer) exit(tid int, ws syscall.WaitStatus, ru syscall.Rusage, now time.Time) {
+	t.mu.Lock()
+	delete(t.tasks, tid)
+	t.mu.Unlock()
+	if tid == t.pid {
+		t.status = ws
+		t.rusage = ru
+		t.exited.Store(true)
+	}
+
+	switch {
+	case ws.Exited():
+		t.event(now, "exit", tid, ws.ExitStatus(), "")
+	case ws.Signaled():
+		detail := sys.SignalName(ws.Signal())
+		if ws.CoreDump() {
+			detail += " (core dumped)"
+		}
+		t.event(now, "killed", tid, int(ws.Signal()), detail)
+	}
+}
+
+// syscall handles a syscall-entry or syscall-exit stop of a traced task.
+func (t *tracer) syscall(tid int, task *tracee, now time.Time) {
+	nr, ret, err := syscallRegs(tid)
+	if err != nil {
+		return
+	}
+	if !task.insys {
+		task.insys = true
+		task.nr = nr
+		task.entry = now
+		return
+	}
+	task.insys = false
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, stats := range []map[int]*syscallStat{t.total, t.delta} {
+		st, ok := stats[task.nr]
+		if !ok {
+			st = new(syscallStat)
+			stats[task.nr] = st
+		}
+		st.calls++
+		st.time += now.Sub(task.entry)
+		if -4096 < ret && ret < 0 {
+			st.errors++
+		}
+	}
+}
+
+// leftovers returns the traced processes still running, sorted by pid.
+func (t *tracer) leftovers() []int {
+	// only thread group leaders are listed in /proc.
+	stats, err := scanProcs()
+	if err != nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var pids []int
+	for tid := range t.tasks {
+		if _, ok := stats[tid]; ok {
+			pids = append(pids, tid)
+		}
+	}
+	slices.Sort(pids)
+	return pids
+}
+
+// detach detaches the tracer from all the traced tasks, which are left
+// running.
+// The tasks are stopped with SIGSTOP, detached at their next ptrace stop and
+// resumed with SIGCONT.
+func (t *tracer) detach() {
+	t.detaching.Store(true)
+	for _, pid := range t.leftovers() {
+		_ = sys.Kill(pid, sys.SIGSTOP)
+	}
+}
+
+// release detaches the tracer from the provided stopped task.
+func (t *tracer) release(tid int, ws syscall.WaitStatus, started bool) {
+	var sig sys.Signal
+	switch s := ws.StopSignal(); {
+	case s == sys.SIGSTOP, s == sys.SIGTRAP, s == sys.SIGTRAP|0x80:
+		// suppress the SIGSTOP sent by detach, and the ptrace stops.
+	case started && !isGroupStop(tid):
+		sig = s
+	}
+	_, _, _ = sys.Syscall6(
+		sys.SYS_PTRACE, sys.PTRACE_DETACH,
+		uintptr(tid), 0, uintptr(sig),
+		0, 0,
+	)
+	_ = sys.Kill(tid, sys.SIGCONT)
+
+	t.mu.Lock()
+	delete(t.tasks, tid)
+	t.mu.Unlock()
+}
+
+// forkEvents holds the names of the lifecycle events of the ptrace
+// fork, vfork and clone events.
+var forkEvents = map[int]string{
+	sys.PTRACE_EVENT_FORK:  "fork",
+	sys.PTRACE_EVENT_VFORK: "vfork",
+	sys.PTRACE_EVENT_CLONE: "clone",
+}
+
+// event records a lifecycle event.
+func (t *tracer) event(now time.Time, kind string, pid, value int, detail string) {
+	if !t.lifecycle {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.evts = append(t.evts, EventInfos{
+		Time:   now.Sub(t.start),
+		Kind:   kind,
+		PID:    pid,
+		Value:  value,
+		Detail: detail,
+	})
+}
+
+// events returns the lifecycle events recorded since the last call to
+// events.
+func (t *tracer) events() []EventInfos {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := t.evts
+	t.evts = nil
+	return out
+}
+
+// readArgv returns the space-separated command line arguments of the
+// provided process.
+func readArgv(pid int) string {
+	argv, _ := readCmdline(pid)
+	return strings.Join(argv, " ")
+}
+
+// interval returns the syscall statistics since the last call to interval.
+func (t *tracer) interval() []StraceInfos {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := straceInfos(t.delta)
+	clear(t.delta)
+	return out
+}
+
+// summary reports the syscall statistics of the whole run on msg, in the
+// fashion of 'strace -c'.
+func (t *tracer) summary(msg *log.Logger) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := straceInfos(t.total)
+	if len(stats) == 0 {
+		return
+	}
+	slices.SortFunc(stats, func(a, b StraceInfos) int {
+		if o := cmp.Compare(b.Time, a.Time); o != 0 {
+			return o
+		}
+		return cmp.Compare(a.Nr, b.Nr)
+	})
+
+	var tot StraceInfos
+	for _, v := range stats {
+		tot.Calls += v.Calls
+		tot.Errors += v.Errors
+		tot.Time += v.Time
+	}
+
+	const sep = "------ ----------- ----------- --------- --------- ----------------"
+	msg.Printf("%6s %11s %11s %9s %9s %s", "% time", "seconds", "usecs/call", "calls", "errors", "syscall")
+	msg.Printf(sep)
+	for _, v := range stats {
+		pct := 0.0
+		if tot.Time > 0 {
+			pct = float64(v.Time) / float64(tot.Time) * 100
+		}
+		msg.Printf(
+			"%6.2f %11.6f %11d %9d %9s %s",
+			pct, v.Time.Seconds(), v.Time.Microseconds()/v.Calls,
+			v.Calls, errCount(v.Errors), v.Name,
+		)
+	}
+	msg.Printf(sep)
+	msg.Printf(
+		"%6.2f %11.6f %11s %9d %9s %s",
+		100.0, tot.Time.Seconds(), "", tot.Calls, errCount(tot.Errors), "total",
+	)
+}
+
+// errCount formats a number of errors, leaving it blank when zero.
+func errCount(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprint(n)
+}
+
+// straceInfos returns the provided syscall statistics, sorted by syscall
+// number.
+func straceInfos(stats map[int]*syscallStat) []StraceInfos {
+	out := make([]StraceInfos, 0, len(stats))
+	for nr, st := range stats {
+		out = append(out, StraceInfos{
+			Nr:     nr,
+			Name:   syscallName(nr),
+			Calls:  st.calls,
+			Errors: st.errors,
+			Time:   st.time,
+		})
+	}
+	slices.SortFunc(out, func(a, b StraceInfos) int { return cmp.Compare(a.Nr, b.Nr) })
+	return out
+}
+
+// isGroupStop reports whether the provided stopped task is in a group-stop,
+// rather than in a signal-delivery-stop.
+func isGroupStop(tid int) bool {
+	var info [128]byte // siginfo_t
+	_, _, errno := sys.Syscall6(
+		sys.SYS_PTRACE, sys.PTRACE_GETSIGINFO,
+		uintptr(tid), 0, uintptr(unsafe.Pointer(&info[0])),
+		0, 0,
+	)
+	return errno == sys.EINVAL
+}
@@ -0,0 +1,22 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pmon
+
+import (
+	sys "golang.org/x/sys/unix"
+)
+
+const syscallRegsSupported = true
+
+// syscallRegs returns the syscall number and return value of the provided
+// task, stopped at a syscall entry or exit.
+func syscallRegs(tid int) (nr int, ret int64, err error) {
+	var regs sys.PtraceRegs
+	err = sys.PtraceGetRegs(tid, &regs)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(int64(regs.Orig_rax)), int64(regs.Rax), nil
+}
@@ -0,0 +1,15 @@
+// Copyright 2026 The pmon Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux && !amd64 && !arm64
+
+package pmon
+
+import "fmt"
+
+const syscallRegsSupported = false
+
+func syscallRegs(tid int) (nr int, ret int64, err error) {
+	return 0, 0, fmt.Errorf("syscall registers not supported on this architecture")
+}